@@ -0,0 +1,111 @@
+package bars
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/schmidthole/ibkr-webapi-go/ibkr"
+)
+
+// a run of Missing expected bars, the first opening at From and the last closing at To.
+type Gap struct {
+	From    time.Time
+	To      time.Time
+	Missing int
+}
+
+// the expected bar open times strictly between prev and next, grouped into contiguous gaps. with
+// a session only bars on the open + n * size grid within regular trading hours are expected, so
+// nights, weekends and holidays are not gaps. without one a bar is expected every size around the
+// clock.
+func gapsBetween(prev time.Time, next time.Time, size time.Duration, session *Session) []Gap {
+	if session == nil {
+		missing := int((next.Sub(prev)+size-1)/size) - 1
+		if missing <= 0 {
+			return nil
+		}
+
+		from := prev.Add(size)
+		return []Gap{{From: from, To: from.Add(time.Duration(missing) * size), Missing: missing}}
+	}
+
+	var gaps []Gap
+	for day := session.midnight(prev); day.Before(next); day = session.midnight(day.Add(36 * time.Hour)) {
+		if !session.IsTradingDay(day) {
+			continue
+		}
+
+		open, close := session.Bounds(day)
+
+		var gap Gap
+		for expected := open; expected.Before(close) && expected.Before(next); expected = expected.Add(size) {
+			if !expected.After(prev) {
+				continue
+			}
+
+			if gap.Missing == 0 {
+				gap.From = expected.UTC()
+			}
+			gap.Missing++
+			gap.To = expected.Add(size).UTC()
+		}
+
+		if gap.Missing > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+
+	return gaps
+}
+
+// flags the bars missing between the first and last bar. bars are expected every size, or only
+// during regular trading hours when a session is given. a nil session suits markets that trade
+// around the clock, for intraday bars of exchange traded instruments it reports every night,
+// weekend and holiday as a gap, so pass the exchange session.
+func FindGaps(bars []ibkr.OHLCBar, size time.Duration, session *Session) ([]Gap, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid bar size: %v", size)
+	}
+
+	sortedBars := sorted(bars)
+
+	var gaps []Gap
+	for i := 1; i < len(sortedBars); i++ {
+		gaps = append(gaps, gapsBetween(sortedBars[i-1].Time(), sortedBars[i].Time(), size, session)...)
+	}
+
+	return gaps, nil
+}
+
+// inserts a bar for every missing bar found by FindGaps. filled bars carry the previous close as
+// their open, high, low and close with no volume. as with FindGaps, a nil session fills nights,
+// weekends and holidays between intraday bars.
+func FillGaps(bars []ibkr.OHLCBar, size time.Duration, session *Session) ([]ibkr.OHLCBar, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid bar size: %v", size)
+	}
+
+	sortedBars := sorted(bars)
+
+	var filled []ibkr.OHLCBar
+	for i, b := range sortedBars {
+		if i > 0 {
+			prev := sortedBars[i-1]
+			for _, gap := range gapsBetween(prev.Time(), b.Time(), size, session) {
+				for n := 0; n < gap.Missing; n++ {
+					filled = append(filled, ibkr.OHLCBar{
+						T: barTime(gap.From.Add(time.Duration(n) * size)),
+						O: prev.C,
+						H: prev.C,
+						L: prev.C,
+						C: prev.C,
+					})
+				}
+			}
+		}
+
+		filled = append(filled, b)
+	}
+
+	return filled, nil
+}
@@ -0,0 +1,69 @@
+package bars
+
+import (
+	"testing"
+	"time"
+
+	"github.com/schmidthole/ibkr-webapi-go/ibkr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindGaps(t *testing.T) {
+	start := time.Date(2023, 12, 11, 0, 0, 0, 0, time.UTC)
+	fiveMinuteBars := []ibkr.OHLCBar{
+		testBar(start, 1, 1, 1, 1, 1),
+		testBar(start.Add(15*time.Minute), 2, 2, 2, 2, 1),
+		testBar(start.Add(20*time.Minute), 3, 3, 3, 3, 1),
+	}
+
+	gaps, err := FindGaps(fiveMinuteBars, 5*time.Minute, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []Gap{{From: start.Add(5 * time.Minute), To: start.Add(15 * time.Minute), Missing: 2}}, gaps)
+
+	filled, err := FillGaps(fiveMinuteBars, 5*time.Minute, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []ibkr.OHLCBar{
+		testBar(start, 1, 1, 1, 1, 1),
+		testBar(start.Add(5*time.Minute), 1, 1, 1, 1, 0),
+		testBar(start.Add(10*time.Minute), 1, 1, 1, 1, 0),
+		testBar(start.Add(15*time.Minute), 2, 2, 2, 2, 1),
+		testBar(start.Add(20*time.Minute), 3, 3, 3, 3, 1),
+	}, filled)
+
+	_, err = FindGaps(fiveMinuteBars, 0, nil)
+	assert.Error(t, err)
+}
+
+func TestFindGaps_session(t *testing.T) {
+	session := USEquitySession()
+	session.Holidays = []time.Time{time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC)}
+
+	// friday before christmas, the following tuesday and wednesday in new york
+	friday := time.Date(2023, 12, 22, 14, 30, 0, 0, time.UTC)
+	tuesday := time.Date(2023, 12, 26, 14, 30, 0, 0, time.UTC)
+	wednesday := time.Date(2023, 12, 27, 14, 30, 0, 0, time.UTC)
+
+	fiveMinuteBars := []ibkr.OHLCBar{
+		testBar(friday.Add(380*time.Minute), 1, 1, 1, 1, 1),
+		testBar(friday.Add(385*time.Minute), 2, 2, 2, 2, 1),
+		testBar(tuesday, 3, 3, 3, 3, 1),
+		testBar(tuesday.Add(375*time.Minute), 4, 4, 4, 4, 1),
+		testBar(wednesday.Add(10*time.Minute), 5, 5, 5, 5, 1),
+	}
+
+	gaps, err := FindGaps(fiveMinuteBars, 5*time.Minute, session)
+	assert.NoError(t, err)
+	assert.Equal(t, []Gap{
+		{From: tuesday.Add(5 * time.Minute), To: tuesday.Add(375 * time.Minute), Missing: 74},
+		{From: tuesday.Add(380 * time.Minute), To: tuesday.Add(390 * time.Minute), Missing: 2},
+		{From: wednesday, To: wednesday.Add(10 * time.Minute), Missing: 2},
+	}, gaps)
+
+	filled, err := FillGaps(fiveMinuteBars, 5*time.Minute, session)
+	assert.NoError(t, err)
+	assert.Len(t, filled, len(fiveMinuteBars)+78)
+
+	refilled, err := FindGaps(filled, 5*time.Minute, session)
+	assert.NoError(t, err)
+	assert.Empty(t, refilled)
+}
@@ -0,0 +1,116 @@
+package bars
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/schmidthole/ibkr-webapi-go/ibkr"
+)
+
+func barTime(t time.Time) int {
+	return int(t.UnixMilli())
+}
+
+// copies bars sorted oldest first.
+func sorted(bars []ibkr.OHLCBar) []ibkr.OHLCBar {
+	sortedBars := slices.Clone(bars)
+	slices.SortStableFunc(sortedBars, func(a, b ibkr.OHLCBar) int { return a.T - b.T })
+	return sortedBars
+}
+
+const (
+	dailyBarSize  = 24 * time.Hour
+	weeklyBarSize = 7 * dailyBarSize
+)
+
+// with a session, daily and weekly buckets start at the local midnight of the day or of the monday
+// so they follow the exchange calendar rather than utc.
+func bucketStart(t time.Time, size time.Duration, session *Session) time.Time {
+	if session == nil {
+		return t.Truncate(size)
+	}
+
+	switch size {
+	case dailyBarSize:
+		return session.midnight(t)
+	case weeklyBarSize:
+		midnight := session.midnight(t)
+		return midnight.AddDate(0, 0, -(int(midnight.Weekday())+6)%7)
+	}
+
+	anchor := session.anchor(t)
+	return anchor.Add(t.Sub(anchor).Truncate(size))
+}
+
+// folds b into the aggregate bar, b must not be older than the bars already in it.
+func aggregate(into *ibkr.OHLCBar, b ibkr.OHLCBar) {
+	into.H = max(into.H, b.H)
+	into.L = min(into.L, b.L)
+	into.C = b.C
+	into.V += b.V
+}
+
+func group(bars []ibkr.OHLCBar, start func(t time.Time) (time.Time, bool)) []ibkr.OHLCBar {
+	var grouped []ibkr.OHLCBar
+	for _, b := range sorted(bars) {
+		bucket, ok := start(b.Time())
+		if !ok {
+			continue
+		}
+
+		if len(grouped) > 0 && grouped[len(grouped)-1].T == barTime(bucket) {
+			aggregate(&grouped[len(grouped)-1], b)
+			continue
+		}
+
+		b.T = barTime(bucket)
+		grouped = append(grouped, b)
+	}
+
+	return grouped
+}
+
+// combines bars into bars of the given size. the open comes from the first bar, the close from the
+// last, the high and low are the extremes and volumes are summed. buckets are aligned to utc
+// midnight, or to the local midnight, open and close when a session is given. with a session the
+// size must fit within the session, or be one day or one week to group by local trading day or
+// week. buckets with only some of their bars are still returned.
+func Resample(bars []ibkr.OHLCBar, size time.Duration, session *Session) ([]ibkr.OHLCBar, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid bar size: %v", size)
+	}
+
+	if session != nil && size != dailyBarSize && size != weeklyBarSize && size > session.Close-session.Open {
+		return nil, fmt.Errorf("bar size %v is longer than the session, use one day or one week", size)
+	}
+
+	return group(bars, func(t time.Time) (time.Time, bool) {
+		return bucketStart(t, size, session), true
+	}), nil
+}
+
+// combines the bars of each regular trading session into one bar opening at the session open.
+// bars outside regular trading hours are dropped.
+func ResampleSessions(bars []ibkr.OHLCBar, session *Session) []ibkr.OHLCBar {
+	return group(bars, func(t time.Time) (time.Time, bool) {
+		if !session.Contains(t) {
+			return time.Time{}, false
+		}
+
+		open, _ := session.Bounds(t)
+		return open, true
+	})
+}
+
+// keeps only the bars opening within regular trading hours.
+func FilterSession(bars []ibkr.OHLCBar, session *Session) []ibkr.OHLCBar {
+	var filtered []ibkr.OHLCBar
+	for _, b := range bars {
+		if session.Contains(b.Time()) {
+			filtered = append(filtered, b)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,109 @@
+package bars
+
+import (
+	"testing"
+	"time"
+
+	"github.com/schmidthole/ibkr-webapi-go/ibkr"
+	"github.com/stretchr/testify/assert"
+)
+
+func testBar(t time.Time, o float64, h float64, l float64, c float64, v float64) ibkr.OHLCBar {
+	return ibkr.OHLCBar{T: barTime(t), O: o, H: h, L: l, C: c, V: v}
+}
+
+// 2023-12-11 in new york, where the session opens at 14:30 utc
+func testNewYorkTime(hour int, minute int) time.Time {
+	return time.Date(2023, 12, 11, hour+5, minute, 0, 0, time.UTC)
+}
+
+func TestResample(t *testing.T) {
+	minuteBars := []ibkr.OHLCBar{
+		testBar(testNewYorkTime(9, 31), 101, 104, 100, 103, 20),
+		testBar(testNewYorkTime(9, 30), 100, 102, 99, 101, 10),
+		testBar(testNewYorkTime(9, 34), 103, 103, 98, 99, 5),
+		testBar(testNewYorkTime(9, 35), 99, 100, 97, 98, 1),
+	}
+
+	resampled, err := Resample(minuteBars, 5*time.Minute, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []ibkr.OHLCBar{
+		testBar(testNewYorkTime(9, 30), 100, 104, 98, 99, 35),
+		testBar(testNewYorkTime(9, 35), 99, 100, 97, 98, 1),
+	}, resampled)
+
+	_, err = Resample(minuteBars, 0, nil)
+	assert.Error(t, err)
+}
+
+func TestResample_session(t *testing.T) {
+	session := USEquitySession()
+
+	minuteBars := []ibkr.OHLCBar{
+		testBar(testNewYorkTime(9, 0), 1, 1, 1, 1, 1),
+		testBar(testNewYorkTime(9, 29), 2, 2, 2, 2, 1),
+		testBar(testNewYorkTime(9, 30), 3, 3, 3, 3, 1),
+		testBar(testNewYorkTime(10, 29), 4, 4, 4, 4, 1),
+		testBar(testNewYorkTime(10, 30), 5, 5, 5, 5, 1),
+		testBar(testNewYorkTime(15, 59), 6, 6, 6, 6, 1),
+		testBar(testNewYorkTime(16, 0), 7, 7, 7, 7, 1),
+	}
+
+	// hourly bars start at the open and do not mix regular and extended hours
+	resampled, err := Resample(minuteBars, time.Hour, session)
+	assert.NoError(t, err)
+	assert.Equal(t, []ibkr.OHLCBar{
+		testBar(testNewYorkTime(9, 0), 1, 2, 1, 2, 2),
+		testBar(testNewYorkTime(9, 30), 3, 4, 3, 4, 2),
+		testBar(testNewYorkTime(10, 30), 5, 5, 5, 5, 1),
+		testBar(testNewYorkTime(15, 30), 6, 6, 6, 6, 1),
+		testBar(testNewYorkTime(16, 0), 7, 7, 7, 7, 1),
+	}, resampled)
+
+	assert.Len(t, FilterSession(minuteBars, session), 4)
+}
+
+func TestResample_sessionWeekly(t *testing.T) {
+	session := USEquitySession()
+	nextDay := 24 * time.Hour
+
+	// monday 2023-12-11 through wednesday 2023-12-20
+	var dailyBars []ibkr.OHLCBar
+	for i, offset := range []int{0, 1, 2, 3, 4, 7, 8, 9} {
+		price := float64(i + 1)
+		dailyBars = append(dailyBars, testBar(testNewYorkTime(9, 30).Add(time.Duration(offset)*nextDay), price, price, price, price, 1))
+	}
+
+	// weeks start at midnight on monday in new york
+	resampled, err := Resample(dailyBars, 7*nextDay, session)
+	assert.NoError(t, err)
+	assert.Equal(t, []ibkr.OHLCBar{
+		testBar(testNewYorkTime(0, 0), 1, 5, 1, 5, 5),
+		testBar(testNewYorkTime(0, 0).Add(7*nextDay), 6, 8, 6, 8, 3),
+	}, resampled)
+
+	resampled, err = Resample(dailyBars, nextDay, session)
+	assert.NoError(t, err)
+	assert.Len(t, resampled, 8)
+	assert.Equal(t, testNewYorkTime(0, 0).Add(nextDay), resampled[1].Time())
+
+	_, err = Resample(dailyBars, 2*nextDay, session)
+	assert.Error(t, err)
+}
+
+func TestResampleSessions(t *testing.T) {
+	session := USEquitySession()
+	nextDay := 24 * time.Hour
+
+	minuteBars := []ibkr.OHLCBar{
+		testBar(testNewYorkTime(9, 0), 1, 50, 1, 1, 100),
+		testBar(testNewYorkTime(9, 30), 10, 12, 9, 11, 1),
+		testBar(testNewYorkTime(15, 59), 11, 13, 8, 12, 2),
+		testBar(testNewYorkTime(9, 30).Add(nextDay), 12, 12, 12, 12, 3),
+	}
+
+	assert.Equal(t, []ibkr.OHLCBar{
+		testBar(testNewYorkTime(9, 30), 10, 13, 8, 12, 3),
+		testBar(testNewYorkTime(9, 30).Add(nextDay), 12, 12, 12, 12, 3),
+	}, ResampleSessions(minuteBars, session))
+}
@@ -0,0 +1,118 @@
+package bars
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// the regular trading hours of an exchange. Open and Close are wall clock offsets from local
+// midnight in Location so sessions follow daylight saving changes. Weekdays defaults to monday
+// through friday and Holidays are matched by their calendar date.
+//
+// time zones are loaded from the host zoneinfo database, applications running on hosts without one
+// should import _ "time/tzdata" to embed it.
+type Session struct {
+	Location *time.Location
+	Open     time.Duration
+	Close    time.Duration
+	Weekdays []time.Weekday
+	Holidays []time.Time
+}
+
+func NewSession(timeZone string, open time.Duration, close time.Duration) (*Session, error) {
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	if open < 0 || close <= open || close > 24*time.Hour {
+		return nil, fmt.Errorf("invalid session hours, open: %v, close: %v", open, close)
+	}
+
+	return &Session{
+		Location: location,
+		Open:     open,
+		Close:    close,
+	}, nil
+}
+
+// regular trading hours for NYSE and NASDAQ listed stocks. panics when the America/New_York time
+// zone cannot be loaded, see Session.
+func USEquitySession() *Session {
+	session, err := NewSession("America/New_York", 9*time.Hour+30*time.Minute, 16*time.Hour)
+	if err != nil {
+		panic(err)
+	}
+
+	return session
+}
+
+func (s *Session) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+	return s.Location
+}
+
+func (s *Session) wallClock(day time.Time, offset time.Duration) time.Time {
+	year, month, date := day.In(s.location()).Date()
+	return time.Date(year, month, date, 0, 0, int(offset/time.Second), 0, s.location())
+}
+
+// the start of the local day containing t.
+func (s *Session) midnight(t time.Time) time.Time {
+	return s.wallClock(t, 0)
+}
+
+func (s *Session) IsTradingDay(t time.Time) bool {
+	local := t.In(s.location())
+
+	weekdays := s.Weekdays
+	if weekdays == nil {
+		weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	}
+
+	if !slices.Contains(weekdays, local.Weekday()) {
+		return false
+	}
+
+	year, month, date := local.Date()
+	for _, holiday := range s.Holidays {
+		holidayYear, holidayMonth, holidayDate := holiday.Date()
+		if year == holidayYear && month == holidayMonth && date == holidayDate {
+			return false
+		}
+	}
+
+	return true
+}
+
+// the open and close of the session on the local day containing t, whether or not it trades.
+func (s *Session) Bounds(t time.Time) (time.Time, time.Time) {
+	return s.wallClock(t, s.Open), s.wallClock(t, s.Close)
+}
+
+func (s *Session) Contains(t time.Time) bool {
+	if !s.IsTradingDay(t) {
+		return false
+	}
+
+	open, close := s.Bounds(t)
+	return !t.Before(open) && t.Before(close)
+}
+
+// the latest of local midnight, the open and the close at or before t. bars are aligned to these
+// so resampled bars never span pre market, regular and after hours trading.
+func (s *Session) anchor(t time.Time) time.Time {
+	open, close := s.Bounds(t)
+
+	switch {
+	case !t.Before(close):
+		return close
+	case !t.Before(open):
+		return open
+	default:
+		return s.midnight(t)
+	}
+}
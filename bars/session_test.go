@@ -0,0 +1,37 @@
+package bars
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	session := USEquitySession()
+	session.Holidays = []time.Time{time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC)}
+
+	// daylight saving time starts on 2023-03-12 in new york
+	open, close := session.Bounds(time.Date(2023, 3, 10, 20, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2023, 3, 10, 14, 30, 0, 0, time.UTC), open.UTC())
+	assert.Equal(t, time.Date(2023, 3, 10, 21, 0, 0, 0, time.UTC), close.UTC())
+
+	open, close = session.Bounds(time.Date(2023, 3, 13, 20, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2023, 3, 13, 13, 30, 0, 0, time.UTC), open.UTC())
+	assert.Equal(t, time.Date(2023, 3, 13, 20, 0, 0, 0, time.UTC), close.UTC())
+
+	assert.True(t, session.Contains(time.Date(2023, 12, 11, 14, 30, 0, 0, time.UTC)))
+	assert.False(t, session.Contains(time.Date(2023, 12, 11, 14, 29, 0, 0, time.UTC)))
+	assert.False(t, session.Contains(time.Date(2023, 12, 11, 21, 0, 0, 0, time.UTC)))
+
+	// a utc date after midnight is still the previous trading day in new york
+	assert.True(t, session.IsTradingDay(time.Date(2023, 12, 16, 1, 0, 0, 0, time.UTC)))
+	assert.False(t, session.IsTradingDay(time.Date(2023, 12, 16, 15, 0, 0, 0, time.UTC)))
+	assert.False(t, session.IsTradingDay(time.Date(2023, 12, 25, 15, 0, 0, 0, time.UTC)))
+
+	_, err := NewSession("America/New_York", 16*time.Hour, 9*time.Hour)
+	assert.Error(t, err)
+
+	_, err = NewSession("Not/AZone", 0, time.Hour)
+	assert.Error(t, err)
+}
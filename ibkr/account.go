@@ -1,6 +1,7 @@
 package ibkr
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 )
@@ -14,13 +15,17 @@ type GetAccountsResponse struct {
 }
 
 func (c *IbkrWebClient) GetAccounts() ([]string, error) {
-	response, err := c.Get("/iserver/accounts", nil)
+	return c.GetAccountsCtx(context.Background())
+}
+
+func (c *IbkrWebClient) GetAccountsCtx(ctx context.Context) ([]string, error) {
+	response, err := c.GetCtx(ctx, "/iserver/accounts", nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad get accounts responseCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
 	var responseStruct GetAccountsResponse
@@ -50,17 +55,21 @@ type SwitchAccountResponseAlreadySet struct {
 }
 
 func (c *IbkrWebClient) SwitchAccount(accountId string) error {
+	return c.SwitchAccountCtx(context.Background(), accountId)
+}
+
+func (c *IbkrWebClient) SwitchAccountCtx(ctx context.Context, accountId string) error {
 	requestBody := SwitchAccountRequest{
 		AccountID: accountId,
 	}
 
-	response, err := c.Post("/iserver/account", nil, requestBody)
+	response, err := c.PostCtx(ctx, "/iserver/account", nil, requestBody)
 	if err != nil {
 		return err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return fmt.Errorf("bad switch account responseCode: %v", response.statusCode)
+		return newResponseError(response)
 	}
 
 	var responseStruct SwitchAccountResponse
@@ -2,13 +2,16 @@ package ibkr
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -27,16 +30,27 @@ const (
 var IbkrGlobalRateLimit = 50
 
 type clientResponse struct {
+	method     string
+	path       string
 	statusCode int
+	header     http.Header
 	bytes      []byte
 }
 
 type IbkrWebClient struct {
-	VerboseLogging bool
-	BaseUrl        string
-	client         *http.Client
-	oauth          OAuthContext
-	validator      *validator.Validate
+	VerboseLogging      bool
+	BaseUrl             string
+	RateLimiter         *RateLimiter
+	RetryPolicy         *RetryPolicy
+	AutoReAuthenticate  bool
+	OrderReplyPolicy    OrderReplyPolicy
+	MaxOrderReplyRounds int
+	HistoryCache        *HistoryCache
+	client              *http.Client
+	oauth               OAuthContext
+	validator           *validator.Validate
+	authMu              sync.Mutex
+	authGeneration      uint64
 }
 
 func NewIbkrWebClient(baseUrl string, authContext OAuthContext) *IbkrWebClient {
@@ -49,11 +63,15 @@ func NewIbkrWebClient(baseUrl string, authContext OAuthContext) *IbkrWebClient {
 	}
 
 	return &IbkrWebClient{
-		VerboseLogging: false,
-		BaseUrl:        baseUrl,
-		client:         &client,
-		oauth:          authContext,
-		validator:      validator.New(validator.WithRequiredStructEnabled()),
+		VerboseLogging:      false,
+		BaseUrl:             baseUrl,
+		RateLimiter:         NewDefaultRateLimiter(),
+		RetryPolicy:         NewDefaultRetryPolicy(),
+		AutoReAuthenticate:  authContext != nil,
+		MaxOrderReplyRounds: DefaultMaxOrderReplyRounds,
+		client:              &client,
+		oauth:               authContext,
+		validator:           validator.New(validator.WithRequiredStructEnabled()),
 	}
 }
 
@@ -62,6 +80,16 @@ func (c *IbkrWebClient) DoRequest(
 	path string,
 	queryParams map[string]string,
 	body interface{},
+) (*clientResponse, error) {
+	return c.DoRequestCtx(context.Background(), method, path, queryParams, body)
+}
+
+func (c *IbkrWebClient) DoRequestCtx(
+	ctx context.Context,
+	method string,
+	path string,
+	queryParams map[string]string,
+	body interface{},
 ) (*clientResponse, error) {
 	base, err := url.Parse(c.BaseUrl)
 	if err != nil {
@@ -78,22 +106,99 @@ func (c *IbkrWebClient) DoRequest(
 		requestUrl.RawQuery = params.Encode()
 	}
 
-	var requestBody io.Reader
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
+		jsonBody, err = json.Marshal(body)
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	attempt := 1
+	for {
+		response, err := c.doAuthenticatedRequest(ctx, method, path, requestUrl.String(), jsonBody)
+		if !c.shouldRetry(ctx, method, attempt, response, err) {
+			return response, err
+		}
+
+		delay := c.RetryPolicy.backoff(attempt, response)
+		if c.VerboseLogging {
+			log.Printf("---- ibkr retrying %s %s in %v, attempt %d failed", method, path, delay, attempt)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+
+		attempt++
+	}
+}
+
+// refreshes the live session token before signing when it is near expiry, and on a 401 resets
+// it and replays the request once.
+func (c *IbkrWebClient) doAuthenticatedRequest(
+	ctx context.Context,
+	method string,
+	path string,
+	requestUrl string,
+	jsonBody []byte,
+) (*clientResponse, error) {
+	if c.oauth == nil || !c.AutoReAuthenticate {
+		return c.doRequestOnce(ctx, method, path, requestUrl, jsonBody)
+	}
+
+	generation, err := c.ensureAuthenticated(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.doRequestOnce(ctx, method, path, requestUrl, jsonBody)
+	if err != nil || response.statusCode != http.StatusUnauthorized {
+		return response, err
+	}
+
+	if c.VerboseLogging {
+		log.Printf("---- ibkr unauthorized response for %s %s, re-authenticating", method, path)
+	}
+
+	err = c.reAuthenticate(ctx, generation)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.doRequestOnce(ctx, method, path, requestUrl, jsonBody)
+}
+
+func (c *IbkrWebClient) doRequestOnce(
+	ctx context.Context,
+	method string,
+	path string,
+	requestUrl string,
+	jsonBody []byte,
+) (*clientResponse, error) {
+	if c.RateLimiter != nil {
+		err := c.RateLimiter.acquire(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var requestBody io.Reader
+	if jsonBody != nil {
 		requestBody = bytes.NewBuffer(jsonBody)
 	}
 
-	request, err := http.NewRequest(method, requestUrl.String(), requestBody)
+	request, err := http.NewRequestWithContext(ctx, method, requestUrl, requestBody)
 	if err != nil {
 		return nil, err
 	}
 
 	if c.oauth != nil {
-		authHeader, err := c.oauth.GetOAuthHeader(method, requestUrl.String())
+		authHeader, err := c.oauth.GetOAuthHeader(method, requestUrl)
 		if err != nil {
 			return nil, err
 		}
@@ -102,7 +207,7 @@ func (c *IbkrWebClient) DoRequest(
 
 	request.Header.Set("User-Agent", DefaultUserAgent)
 
-	if body != nil {
+	if jsonBody != nil {
 		request.Header.Set("Content-Type", "application/json")
 	}
 
@@ -121,30 +226,105 @@ func (c *IbkrWebClient) DoRequest(
 		return nil, err
 	}
 
-	return &clientResponse{statusCode: response.StatusCode, bytes: bodyBytes}, nil
+	return &clientResponse{
+		method:     method,
+		path:       path,
+		statusCode: response.StatusCode,
+		header:     response.Header,
+		bytes:      bodyBytes,
+	}, nil
 }
 
 func (c *IbkrWebClient) Get(path string, queryParams map[string]string) (*clientResponse, error) {
-	return c.DoRequest(methodGet, path, queryParams, nil)
+	return c.GetCtx(context.Background(), path, queryParams)
+}
+
+func (c *IbkrWebClient) GetCtx(ctx context.Context, path string, queryParams map[string]string) (*clientResponse, error) {
+	return c.DoRequestCtx(ctx, methodGet, path, queryParams, nil)
 }
 
 func (c *IbkrWebClient) Post(path string, queryParams map[string]string, body interface{}) (*clientResponse, error) {
-	return c.DoRequest(methodPost, path, queryParams, body)
+	return c.PostCtx(context.Background(), path, queryParams, body)
+}
+
+func (c *IbkrWebClient) PostCtx(
+	ctx context.Context,
+	path string,
+	queryParams map[string]string,
+	body interface{},
+) (*clientResponse, error) {
+	return c.DoRequestCtx(ctx, methodPost, path, queryParams, body)
 }
 
 func (c *IbkrWebClient) Delete(path string, queryParams map[string]string) (*clientResponse, error) {
-	return c.DoRequest(methodDelete, path, queryParams, nil)
+	return c.DeleteCtx(context.Background(), path, queryParams)
+}
+
+func (c *IbkrWebClient) DeleteCtx(ctx context.Context, path string, queryParams map[string]string) (*clientResponse, error) {
+	return c.DoRequestCtx(ctx, methodDelete, path, queryParams, nil)
 }
 
 func (c *IbkrWebClient) Authenticate() error {
-	if !c.oauth.ShouldReAuthenticate() {
-		return nil
+	return c.AuthenticateCtx(context.Background())
+}
+
+func (c *IbkrWebClient) AuthenticateCtx(ctx context.Context) error {
+	_, err := c.ensureAuthenticated(ctx)
+	return err
+}
+
+func (c *IbkrWebClient) ResetAuthentication() {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	c.oauth.Reset()
+}
+
+func (c *IbkrWebClient) generateLiveSessionToken(ctx context.Context) error {
+	oauthCtx, ok := c.oauth.(OAuthContextCtx)
+	if ok {
+		return oauthCtx.GenerateLiveSessionTokenCtx(ctx, c.client, c.BaseUrl)
 	}
+
 	return c.oauth.GenerateLiveSessionToken(c.client, c.BaseUrl)
 }
 
-func (c *IbkrWebClient) ResetAuthentication() {
+// the auth generation is bumped on every new live session token so concurrent callers that
+// observed the same stale token only trigger a single handshake.
+func (c *IbkrWebClient) ensureAuthenticated(ctx context.Context) (uint64, error) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if !c.oauth.ShouldReAuthenticate() {
+		return c.authGeneration, nil
+	}
+
+	err := c.generateLiveSessionToken(ctx)
+	if err != nil {
+		return c.authGeneration, err
+	}
+	c.authGeneration++
+
+	return c.authGeneration, nil
+}
+
+func (c *IbkrWebClient) reAuthenticate(ctx context.Context, generation uint64) error {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.authGeneration != generation {
+		return nil
+	}
+
 	c.oauth.Reset()
+
+	err := c.generateLiveSessionToken(ctx)
+	if err != nil {
+		return err
+	}
+	c.authGeneration++
+
+	return nil
 }
 
 func (c *IbkrWebClient) ParseJsonResponse(response *clientResponse, v interface{}) error {
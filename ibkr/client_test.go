@@ -1,11 +1,15 @@
 package ibkr
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -173,3 +177,107 @@ func TestIbkrWebClient_Delete(t *testing.T) {
 
 	assert.Equal(t, "success", rspStruct.Message)
 }
+
+func TestIbkrWebClient_GetCtxCancelled(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	rsp, err := client.GetCtx(ctx, "/test-endpoint", nil)
+	assert.Nil(t, rsp)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+type rotatingOAuthContext struct {
+	mu          sync.Mutex
+	generations int
+	token       string
+}
+
+func (i *rotatingOAuthContext) GenerateLiveSessionToken(client *http.Client, baseUrl string) error {
+	return i.GenerateLiveSessionTokenCtx(context.Background(), client, baseUrl)
+}
+func (i *rotatingOAuthContext) GenerateLiveSessionTokenCtx(ctx context.Context, client *http.Client, baseUrl string) error {
+	time.Sleep(10 * time.Millisecond)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.generations++
+	i.token = fmt.Sprintf("token-%d", i.generations)
+	return nil
+}
+func (i *rotatingOAuthContext) GetOAuthHeader(method string, requestUrl string) (string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return "OAuth " + i.token, nil
+}
+func (i *rotatingOAuthContext) ShouldReAuthenticate() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.token == ""
+}
+func (i *rotatingOAuthContext) Reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.token = ""
+}
+
+func TestIbkrWebClient_ReAuthenticateOnUnauthorized(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "OAuth token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "success"}`))
+	}))
+	defer mockServer.Close()
+
+	oauth := &rotatingOAuthContext{}
+	client := NewIbkrWebClient(mockServer.URL, oauth)
+
+	err := client.Authenticate()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, oauth.generations)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rsp, err := client.Get("/test-endpoint", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, rsp.statusCode)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, oauth.generations)
+}
+
+func TestIbkrWebClient_AuthenticateBeforeRequest(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "OAuth token-1", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	oauth := &rotatingOAuthContext{}
+	client := NewIbkrWebClient(mockServer.URL, oauth)
+
+	_, err := client.Post("/test-endpoint", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, oauth.generations)
+}
@@ -1,7 +1,7 @@
 package ibkr
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 )
 
@@ -15,19 +15,23 @@ type SearchContractBySymbolResponse struct {
 }
 
 func (c *IbkrWebClient) SearchContractBySymbol(symbol string) ([]SearchContractBySymbolResponse, error) {
+	return c.SearchContractBySymbolCtx(context.Background(), symbol)
+}
+
+func (c *IbkrWebClient) SearchContractBySymbolCtx(ctx context.Context, symbol string) ([]SearchContractBySymbolResponse, error) {
 	params := map[string]string{
 		"symbol":  symbol,
 		"name":    "false",
 		"secType": "STK",
 	}
 
-	response, err := c.Get("/iserver/secdef/search", params)
+	response, err := c.GetCtx(ctx, "/iserver/secdef/search", params)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("search contract by symbol bad statusCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
 	var responseStruct []SearchContractBySymbolResponse
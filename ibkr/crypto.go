@@ -10,49 +10,147 @@ import (
 	"encoding/asn1"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"math/big"
 	"os"
+
+	"github.com/youmark/pkcs8"
 )
 
+var MinDhPrimeBits = 2048
+
 type dhParams struct {
 	P *big.Int
 	G *big.Int
 }
 
 func ImportRsaKeyFromPem(pemPath string) (*rsa.PrivateKey, error) {
+	return ImportEncryptedRsaKeyFromPem(pemPath, nil)
+}
+
+func ImportEncryptedRsaKeyFromPem(pemPath string, passphrase []byte) (*rsa.PrivateKey, error) {
 	pemData, err := os.ReadFile(pemPath)
 	if err != nil {
 		return nil, err
 	}
 
-	block, _ := pem.Decode(pemData)
+	key, err := ParseEncryptedRsaKeyPem(pemData, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("error importing rsa key %s: %w", pemPath, err)
+	}
 
-	pkcs8Key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	return key, nil
+}
+
+func ImportRsaKeyFromReader(r io.Reader) (*rsa.PrivateKey, error) {
+	pemData, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 
+	return ParseRsaKeyPem(pemData)
+}
+
+func ParseRsaKeyPem(pemData []byte) (*rsa.PrivateKey, error) {
+	return ParseEncryptedRsaKeyPem(pemData, nil)
+}
+
+// accepts PKCS#1 and PKCS#8 keys, either unencrypted or as a PKCS#8 "ENCRYPTED PRIVATE KEY". encrypted
+// keys must use PBES2 with a PBKDF2 (HMAC-SHA1 or HMAC-SHA256) or scrypt key derivation and AES-CBC,
+// AES-GCM or 3DES-CBC encryption, anything else is rejected. legacy openssl pem encryption headers
+// are rejected since the format is unauthenticated and derives its key with a single md5 round.
+func ParseEncryptedRsaKeyPem(pemData []byte, passphrase []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in rsa key data")
+	}
+
+	if _, encrypted := block.Headers["DEK-Info"]; encrypted {
+		return nil, fmt.Errorf(
+			"legacy encrypted pem keys are not supported, convert with: openssl pkcs8 -topk8 -v2 aes-256-cbc",
+		)
+	}
+
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+
+		key, err := pkcs8.ParsePKCS8PrivateKeyRSA(block.Bytes, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting PKCS#8 key: %w", err)
+		}
+
+		return key, nil
+	}
+
+	return parseRsaKeyDer(block.Bytes)
+}
+
+// the block type is not trusted since keys are often mislabeled, so both encodings are tried.
+func parseRsaKeyDer(der []byte) (*rsa.PrivateKey, error) {
+	pkcs1Key, err := x509.ParsePKCS1PrivateKey(der)
+	if err == nil {
+		return pkcs1Key, nil
+	}
+
+	pkcs8Key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("rsa key is neither PKCS#1 nor PKCS#8: %w", err)
+	}
+
 	rsaKey, ok := pkcs8Key.(*rsa.PrivateKey)
 	if !ok {
-		return nil, fmt.Errorf("error parsing PKCS#8 rsa key")
+		return nil, fmt.Errorf("PKCS#8 key is not an rsa key, found: %T", pkcs8Key)
 	}
 
 	return rsaKey, nil
 }
 
+/******************************************************************************
+* diffie hellman parameters
+******************************************************************************/
+
 func ImportDhParametersFromPem(pemPath string) (*dsa.Parameters, error) {
 	pemData, err := os.ReadFile(pemPath)
 	if err != nil {
 		return nil, err
 	}
 
+	params, err := ParseDhParametersPem(pemData)
+	if err != nil {
+		return nil, fmt.Errorf("error importing dh params %s: %w", pemPath, err)
+	}
+
+	return params, nil
+}
+
+func ImportDhParametersFromReader(r io.Reader) (*dsa.Parameters, error) {
+	pemData, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseDhParametersPem(pemData)
+}
+
+func ParseDhParametersPem(pemData []byte) (*dsa.Parameters, error) {
 	block, _ := pem.Decode(pemData)
-	if block == nil || block.Type != "DH PARAMETERS" {
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in dh params data")
+	}
+
+	if block.Type != "DH PARAMETERS" {
 		return nil, fmt.Errorf("invalid PEM block type: %s", block.Type)
 	}
 
 	var params dhParams
-	_, err = asn1.Unmarshal(block.Bytes, &params)
+	_, err := asn1.Unmarshal(block.Bytes, &params)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateDhParameters(params.P, params.G)
 	if err != nil {
 		return nil, err
 	}
@@ -64,6 +162,28 @@ func ImportDhParametersFromPem(pemPath string) (*dsa.Parameters, error) {
 	}, nil
 }
 
+func validateDhParameters(p *big.Int, g *big.Int) error {
+	if p == nil || g == nil {
+		return fmt.Errorf("dh params missing prime or generator")
+	}
+
+	if p.BitLen() < MinDhPrimeBits {
+		return fmt.Errorf("dh prime too small, found %d bits, need at least %d", p.BitLen(), MinDhPrimeBits)
+	}
+
+	if !p.ProbablyPrime(20) {
+		return fmt.Errorf("dh prime is not prime")
+	}
+
+	// the generator must lie in [2, p-2] or the shared secret is trivially predictable
+	pMinusOne := new(big.Int).Sub(p, big.NewInt(1))
+	if g.Cmp(big.NewInt(2)) < 0 || g.Cmp(pMinusOne) >= 0 {
+		return fmt.Errorf("dh generator out of range: %v", g)
+	}
+
+	return nil
+}
+
 func SignRsa(input []byte, key *rsa.PrivateKey) ([]byte, error) {
 	hash := sha256.Sum256(input)
 	return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
@@ -0,0 +1,86 @@
+package ibkr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrUnauthorized            = errors.New("ibkr unauthorized")
+	ErrRateLimited             = errors.New("ibkr rate limited")
+	ErrSessionNotAuthenticated = errors.New("ibkr session not authenticated")
+	ErrOrderRejected           = errors.New("ibkr order rejected")
+
+	ErrOrderReplyRoundsExceeded = errors.New("ibkr order reply rounds exceeded")
+	ErrInvalidOrder             = errors.New("invalid order")
+
+	ErrSnapshotIncomplete = errors.New("ibkr market data snapshot incomplete")
+
+	ErrLiveSessionTokenSignature = errors.New("ibkr live session token signature mismatch")
+	ErrPassphraseRequired        = errors.New("pem key is encrypted and requires a passphrase")
+)
+
+type APIError struct {
+	StatusCode int
+	Method     string
+	Endpoint   string
+	Body       []byte
+	IbkrError  string
+	kinds      []error
+}
+
+type apiErrorBody struct {
+	Error string `json:"error"`
+}
+
+func newAPIError(method string, endpoint string, statusCode int, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Method:     method,
+		Endpoint:   endpoint,
+		Body:       body,
+	}
+
+	var errorBody apiErrorBody
+	if json.Unmarshal(body, &errorBody) == nil {
+		apiErr.IbkrError = errorBody.Error
+	}
+
+	switch statusCode {
+	case http.StatusUnauthorized:
+		apiErr.kinds = append(apiErr.kinds, ErrUnauthorized)
+	case http.StatusTooManyRequests:
+		apiErr.kinds = append(apiErr.kinds, ErrRateLimited)
+	}
+
+	if strings.Contains(strings.ToLower(apiErr.IbkrError), "not authenticated") {
+		apiErr.kinds = append(apiErr.kinds, ErrSessionNotAuthenticated)
+	}
+
+	return apiErr
+}
+
+func newResponseError(response *clientResponse) *APIError {
+	return newAPIError(response.method, response.path, response.statusCode, response.bytes)
+}
+
+func newOrderRejectedError(response *clientResponse, reason string) *APIError {
+	apiErr := newResponseError(response)
+	apiErr.IbkrError = reason
+	apiErr.kinds = append(apiErr.kinds, ErrOrderRejected)
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	if e.IbkrError != "" {
+		return fmt.Sprintf("ibkr %s %s statusCode: %v, error: %s", e.Method, e.Endpoint, e.StatusCode, e.IbkrError)
+	}
+	return fmt.Sprintf("ibkr %s %s statusCode: %v", e.Method, e.Endpoint, e.StatusCode)
+}
+
+func (e *APIError) Unwrap() []error {
+	return e.kinds
+}
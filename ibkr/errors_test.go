@@ -0,0 +1,81 @@
+package ibkr
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_newAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		ibkrError  string
+		is         []error
+		isNot      []error
+	}{
+		{
+			name:       "unauthorized",
+			statusCode: http.StatusUnauthorized,
+			body:       `{"error": "not authenticated", "statusCode": 401}`,
+			ibkrError:  "not authenticated",
+			is:         []error{ErrUnauthorized, ErrSessionNotAuthenticated},
+			isNot:      []error{ErrRateLimited, ErrOrderRejected},
+		},
+		{
+			name:       "rate limited",
+			statusCode: http.StatusTooManyRequests,
+			body:       "Too Many Requests",
+			is:         []error{ErrRateLimited},
+			isNot:      []error{ErrUnauthorized, ErrSessionNotAuthenticated},
+		},
+		{
+			name:       "server error",
+			statusCode: http.StatusInternalServerError,
+			body:       `{"error": "internal error"}`,
+			ibkrError:  "internal error",
+			isNot:      []error{ErrUnauthorized, ErrRateLimited, ErrSessionNotAuthenticated, ErrOrderRejected},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newAPIError(methodGet, "/test-endpoint", tt.statusCode, []byte(tt.body))
+
+			assert.Equal(t, tt.statusCode, err.StatusCode)
+			assert.Equal(t, tt.ibkrError, err.IbkrError)
+			assert.Equal(t, []byte(tt.body), err.Body)
+
+			for _, target := range tt.is {
+				assert.ErrorIs(t, err, target)
+			}
+			for _, target := range tt.isNot {
+				assert.NotErrorIs(t, err, target)
+			}
+		})
+	}
+}
+
+func TestIbkrWebClient_APIError(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"error": "not authenticated", "statusCode": 401}`)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	_, err := client.Tickle()
+
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.ErrorIs(t, err, ErrSessionNotAuthenticated)
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "POST", apiErr.Method)
+	assert.Equal(t, "/tickle", apiErr.Endpoint)
+}
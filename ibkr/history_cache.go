@@ -0,0 +1,519 @@
+package ibkr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var DefaultHistoryCacheMaxAge = 30 * 24 * time.Hour
+
+// caches historical bars on disk as one json file per conid, bar size and trading hours setting.
+// when set on the client, history requests are served from the cache and only the ranges missing
+// from it are fetched from ibkr. day periods count trading days, so they are served from the cache
+// once it holds bars on that many distinct utc dates before the period end.
+type HistoryCache struct {
+	Dir string
+	// files not used within MaxAge are evicted, zero disables.
+	MaxAge time.Duration
+	// the least recently used files beyond MaxFiles are evicted, zero disables.
+	MaxFiles int
+	// guards file access and eviction.
+	mu sync.Mutex
+	// serializes requests per key so fetches for different keys run concurrently.
+	keysMu   sync.Mutex
+	keyLocks map[historyCacheKey]*historyKeyLock
+}
+
+func NewHistoryCache(dir string) (*HistoryCache, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("error creating history cache dir %s: %w", dir, err)
+	}
+
+	return &HistoryCache{
+		Dir:    dir,
+		MaxAge: DefaultHistoryCacheMaxAge,
+	}, nil
+}
+
+type historyCacheKey struct {
+	conId      int
+	bar        BarSize
+	outsideRth bool
+}
+
+type historyKeyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// locks the key and returns the unlock func. the lock is dropped once nothing holds or waits on it.
+func (h *HistoryCache) lockKey(key historyCacheKey) func() {
+	h.keysMu.Lock()
+	if h.keyLocks == nil {
+		h.keyLocks = map[historyCacheKey]*historyKeyLock{}
+	}
+
+	lock := h.keyLocks[key]
+	if lock == nil {
+		lock = &historyKeyLock{}
+		h.keyLocks[key] = lock
+	}
+	lock.refs++
+	h.keysMu.Unlock()
+
+	lock.mu.Lock()
+
+	return func() {
+		lock.mu.Unlock()
+
+		h.keysMu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(h.keyLocks, key)
+		}
+		h.keysMu.Unlock()
+	}
+}
+
+func (k historyCacheKey) fileName() string {
+	hours := "rth"
+	if k.outsideRth {
+		hours = "ext"
+	}
+
+	return fmt.Sprintf("%d_%s_%s.json", k.conId, k.bar, hours)
+}
+
+// From and To bound the range that has been fetched, bars within it can still be sparse since
+// there are none outside trading hours.
+type historyCacheEntry struct {
+	ConID           int       `json:"conid"`
+	Bar             BarSize   `json:"bar"`
+	OutsideRth      bool      `json:"outsideRth"`
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	MarketDataDelay int       `json:"mktDataDelay"`
+	Bars            []OHLCBar `json:"bars"`
+}
+
+func (h *HistoryCache) path(key historyCacheKey) string {
+	return filepath.Join(h.Dir, key.fileName())
+}
+
+// returns nil without an error when nothing is cached. unreadable files are treated as missing
+// and overwritten on the next store.
+func (h *HistoryCache) load(key historyCacheKey) (*historyCacheEntry, error) {
+	path := h.path(key)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry historyCacheEntry
+	if json.Unmarshal(data, &entry) != nil || !entry.From.Before(entry.To) {
+		return nil, nil
+	}
+
+	// the modification time doubles as the last use for eviction
+	now := time.Now()
+	err = os.Chtimes(path, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (h *HistoryCache) get(key historyCacheKey) (*historyCacheEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.load(key)
+}
+
+// stores the entry and evicts old files.
+func (h *HistoryCache) put(key historyCacheKey, entry *historyCacheEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// nothing past now has been fetched yet
+	if entry.To.After(time.Now()) {
+		entry.To = time.Now()
+	}
+
+	if entry.From.Before(entry.To) {
+		err := h.store(key, entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return h.evict()
+}
+
+func (h *HistoryCache) store(key historyCacheKey, entry *historyCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	path := h.path(key)
+
+	// write to a temp file first so readers never see a partially written file
+	tempFile, err := os.CreateTemp(h.Dir, key.fileName()+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(data)
+	if err != nil {
+		tempFile.Close()
+		return err
+	}
+
+	err = tempFile.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), path)
+}
+
+// removes files past MaxAge and then the least recently used files beyond MaxFiles.
+func (h *HistoryCache) Evict() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.evict()
+}
+
+func (h *HistoryCache) evict() error {
+	files, err := h.files()
+	if err != nil {
+		return err
+	}
+
+	// most recently used first
+	slices.SortFunc(files, func(a, b fs.FileInfo) int { return b.ModTime().Compare(a.ModTime()) })
+
+	for i, file := range files {
+		expired := h.MaxAge > 0 && time.Since(file.ModTime()) > h.MaxAge
+		overflow := h.MaxFiles > 0 && i >= h.MaxFiles
+		if !expired && !overflow {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(h.Dir, file.Name()))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *HistoryCache) Clear() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	files, err := h.files()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		err = os.Remove(filepath.Join(h.Dir, file.Name()))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *HistoryCache) files() ([]fs.FileInfo, error) {
+	dirEntries, err := os.ReadDir(h.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []fs.FileInfo
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), ".json") {
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, info)
+	}
+
+	return files, nil
+}
+
+/******************************************************************************
+* cached history requests
+******************************************************************************/
+
+// the start of the period counting back from end. day periods count trading days, so their start
+// is the utc date of the count-th distinct bar date before end and false is returned when bars
+// holds fewer dates. months and years are counted on the calendar.
+func historyPeriodStart(period Period, end time.Time, bars []OHLCBar) (time.Time, bool) {
+	count, unit, err := period.parse()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	switch unit {
+	case MarketDataPeriodDay:
+		dates := 0
+		var date time.Time
+		for i := len(bars) - 1; i >= 0; i-- {
+			barTime := bars[i].Time()
+			if !barTime.Before(end) {
+				continue
+			}
+
+			barDate := barTime.Truncate(24 * time.Hour)
+			if dates > 0 && !barDate.Before(date) {
+				continue
+			}
+
+			date = barDate
+			dates++
+			if dates == count {
+				return date, true
+			}
+		}
+
+		return time.Time{}, false
+	case MarketDataPeriodMonth:
+		return end.AddDate(0, -count, 0), true
+	case MarketDataPeriodYear:
+		return end.AddDate(-count, 0, 0), true
+	default:
+		return end.Add(-period.Duration()), true
+	}
+}
+
+// serves the period from the cache when it covers it, fetching the tail after the last cached bar
+// first. otherwise the period is fetched from ibkr, merged into the cache when it overlaps or
+// extends the cached range and returned unchanged.
+func (c *IbkrWebClient) cachedMarketDataHistory(
+	ctx context.Context,
+	request MarketDataHistoryRequest,
+) (*MarketDataHistoryResponse, error) {
+	end := request.StartTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	cache := c.HistoryCache
+	key := historyCacheKey{request.ConID, request.Bar, request.OutsideRth}
+
+	unlock := cache.lockKey(key)
+	defer unlock()
+
+	entry, err := cache.get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil && end.After(entry.To) {
+		tailFrom := entry.To
+		if len(entry.Bars) > 0 {
+			tailFrom = entry.Bars[len(entry.Bars)-1].Time()
+		}
+
+		tail, err := c.marketDataHistoryRange(ctx, key.conId, key.bar, tailFrom, end, key.outsideRth)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Bars = mergeBars(append(entry.Bars, tail...))
+		entry.To = end
+
+		err = cache.put(key, entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if entry != nil {
+		from, ok := historyPeriodStart(request.Period, end, entry.Bars)
+		if ok && !entry.From.After(from) {
+			bars := make([]OHLCBar, 0)
+			for _, b := range entry.Bars {
+				barTime := b.Time()
+				if !barTime.Before(from) && barTime.Before(end) {
+					bars = append(bars, b)
+				}
+			}
+
+			return &MarketDataHistoryResponse{
+				StartTime:       from.UTC().Format(historyStartTimeLayout),
+				Data:            bars,
+				Points:          len(bars),
+				MarketDataDelay: entry.MarketDataDelay,
+			}, nil
+		}
+	}
+
+	history, err := c.marketDataHistory(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(history.Data) == 0 {
+		return history, nil
+	}
+
+	// ibkr returns whole trading days for day periods and the full span for the other units
+	bars := mergeBars(slices.Clone(history.Data))
+	from, ok := historyPeriodStart(request.Period, end, bars)
+	if !ok || bars[0].Time().Before(from) {
+		from = bars[0].Time().Truncate(24 * time.Hour)
+	}
+
+	if !from.Before(end) {
+		return history, nil
+	}
+
+	switch {
+	case entry == nil:
+		entry = &historyCacheEntry{
+			ConID:      key.conId,
+			Bar:        key.bar,
+			OutsideRth: key.outsideRth,
+			From:       from,
+			To:         end,
+		}
+	case !from.After(entry.To) && !end.Before(entry.From):
+		if from.Before(entry.From) {
+			entry.From = from
+		}
+		if end.After(entry.To) {
+			entry.To = end
+		}
+	default:
+		// the cached range must stay contiguous so disjoint bars are not cached
+		return history, nil
+	}
+
+	entry.MarketDataDelay = history.MarketDataDelay
+	entry.Bars = mergeBars(append(entry.Bars, bars...))
+
+	err = cache.put(key, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// serves [from, to) from the cache, fetching whatever lies before or after the cached range. the
+// last cached bar is always fetched again with the tail since it may have been incomplete.
+func (c *IbkrWebClient) cachedMarketDataHistoryRange(
+	ctx context.Context,
+	key historyCacheKey,
+	from time.Time,
+	to time.Time,
+) ([]OHLCBar, error) {
+	cache := c.HistoryCache
+
+	unlock := cache.lockKey(key)
+	defer unlock()
+
+	entry, err := cache.get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var fetched []OHLCBar
+	changed := true
+	if entry == nil {
+		fetched, err = c.marketDataHistoryRange(ctx, key.conId, key.bar, from, to, key.outsideRth)
+		if err != nil {
+			return nil, err
+		}
+
+		entry = &historyCacheEntry{
+			ConID:      key.conId,
+			Bar:        key.bar,
+			OutsideRth: key.outsideRth,
+			From:       from,
+			To:         to,
+		}
+	} else {
+		changed = false
+
+		// the head is fetched up to the cached range even when to is earlier so the cached range
+		// stays contiguous
+		if from.Before(entry.From) {
+			head, err := c.marketDataHistoryRange(ctx, key.conId, key.bar, from, entry.From, key.outsideRth)
+			if err != nil {
+				return nil, err
+			}
+
+			fetched = append(fetched, head...)
+			entry.From = from
+			changed = true
+		}
+
+		if to.After(entry.To) {
+			tailFrom := entry.To
+			if len(entry.Bars) > 0 {
+				tailFrom = entry.Bars[len(entry.Bars)-1].Time()
+			}
+
+			tail, err := c.marketDataHistoryRange(ctx, key.conId, key.bar, tailFrom, to, key.outsideRth)
+			if err != nil {
+				return nil, err
+			}
+
+			fetched = append(fetched, tail...)
+			entry.To = to
+			changed = true
+		}
+	}
+
+	if changed {
+		entry.Bars = mergeBars(append(entry.Bars, fetched...))
+
+		err = cache.put(key, entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bars := make([]OHLCBar, 0)
+	for _, b := range entry.Bars {
+		barTime := b.Time()
+		if !barTime.Before(from) && barTime.Before(to) {
+			bars = append(bars, b)
+		}
+	}
+
+	return bars, nil
+}
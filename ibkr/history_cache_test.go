@@ -0,0 +1,257 @@
+package ibkr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serves one daily bar per day in the requested period, closing at the day number.
+func testDailyHistoryHandler(t *testing.T, requests *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.Query().Get("period"))
+
+		end, err := time.Parse(historyStartTimeLayout, r.URL.Query().Get("startTime"))
+		assert.NoError(t, err)
+
+		var bars []OHLCBar
+		period := Period(r.URL.Query().Get("period"))
+		for barTime := end.Add(-period.Duration()); barTime.Before(end); barTime = barTime.Add(24 * time.Hour) {
+			bars = append(bars, OHLCBar{T: int(barTime.UnixMilli()), C: float64(barTime.Day())})
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(MarketDataHistoryResponse{
+			StartTime:       end.Add(-period.Duration()).Format(historyStartTimeLayout),
+			Data:            bars,
+			Points:          len(bars),
+			MarketDataDelay: 15,
+		})
+	}
+}
+
+func TestIbkrWebClient_MarketDataHistoryCached(t *testing.T) {
+	var requests []string
+	mockServer := httptest.NewServer(testDailyHistoryHandler(t, &requests))
+
+	cache, err := NewHistoryCache(t.TempDir())
+	assert.NoError(t, err)
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+	client.HistoryCache = cache
+
+	end := time.Date(2023, 12, 11, 0, 0, 0, 0, time.UTC)
+	request := MarketDataHistoryRequest{
+		ConID:     testConid,
+		Period:    NewPeriod(10, MarketDataPeriodDay),
+		Bar:       BarSize1Day,
+		StartTime: end,
+	}
+
+	history, err := client.GetMarketDataHistory(request)
+	assert.NoError(t, err)
+	assert.Len(t, history.Data, 10)
+	assert.Equal(t, "20231201-00:00:00", history.StartTime)
+	assert.Equal(t, []string{"10d"}, requests)
+	assert.FileExists(t, filepath.Join(cache.Dir, "1234_1d_rth.json"))
+
+	// fully cached
+	cached, err := client.GetMarketDataHistory(request)
+	assert.NoError(t, err)
+	assert.Equal(t, history, cached)
+	assert.Len(t, requests, 1)
+
+	bars, err := client.GetMarketDataHistoryRange(testConid, BarSize1Day, end.Add(-10*24*time.Hour), end, false)
+	assert.NoError(t, err)
+	assert.Len(t, bars, 10)
+	assert.Len(t, requests, 1)
+
+	// only the tail from the last cached bar onwards is fetched
+	request.StartTime = end.Add(3 * 24 * time.Hour)
+	history, err = client.GetMarketDataHistory(request)
+	assert.NoError(t, err)
+	assert.Len(t, history.Data, 10)
+	assert.Equal(t, []string{"10d", "4d"}, requests)
+	assert.Equal(t, end.Add(-7*24*time.Hour), history.Data[0].Time())
+	assert.Equal(t, 13.0, history.Data[9].C)
+	assert.Equal(t, 15, history.MarketDataDelay)
+
+	// the cache keeps working without ibkr
+	mockServer.Close()
+
+	request.StartTime = end
+	history, err = client.GetMarketDataHistory(request)
+	assert.NoError(t, err)
+	assert.Len(t, history.Data, 10)
+	assert.Equal(t, end.Add(-10*24*time.Hour), history.Data[0].Time())
+	assert.Equal(t, end.Add(-24*time.Hour), history.Data[9].Time())
+
+	bars, err = client.GetMarketDataHistoryRange(testConid, BarSize1Day, end.Add(-2*24*time.Hour), end, false)
+	assert.NoError(t, err)
+	assert.Len(t, bars, 2)
+
+	// periods reaching past the cached trading days go to ibkr
+	request.Period = NewPeriod(20, MarketDataPeriodDay)
+	_, err = client.GetMarketDataHistory(request)
+	assert.Error(t, err)
+
+	// different trading hours are cached separately
+	request.Period = NewPeriod(2, MarketDataPeriodDay)
+	request.OutsideRth = true
+	_, err = client.GetMarketDataHistory(request)
+	assert.Error(t, err)
+}
+
+func Test_historyPeriodStart(t *testing.T) {
+	end := time.Date(2023, 12, 11, 0, 0, 0, 0, time.UTC)
+
+	// trading days skip the weekend without bars
+	var bars []OHLCBar
+	for _, day := range []int{4, 5, 6, 7, 8} {
+		for _, hour := range []int{15, 18} {
+			bars = append(bars, OHLCBar{T: int(time.Date(2023, 12, day, hour, 0, 0, 0, time.UTC).UnixMilli())})
+		}
+	}
+
+	start, ok := historyPeriodStart(NewPeriod(3, MarketDataPeriodDay), end, bars)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2023, 12, 6, 0, 0, 0, 0, time.UTC), start)
+
+	_, ok = historyPeriodStart(NewPeriod(6, MarketDataPeriodDay), end, bars)
+	assert.False(t, ok)
+
+	start, ok = historyPeriodStart(NewPeriod(2, MarketDataPeriodMonth), end, nil)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2023, 10, 11, 0, 0, 0, 0, time.UTC), start)
+
+	start, ok = historyPeriodStart(NewPeriod(1, MarketDataPeriodWeek), end, nil)
+	assert.True(t, ok)
+	assert.Equal(t, end.Add(-7*24*time.Hour), start)
+}
+
+func TestIbkrWebClient_MarketDataHistoryCachedConcurrentKeys(t *testing.T) {
+	var requests []string
+	handler := testDailyHistoryHandler(t, &requests)
+
+	started := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first conid is held until the second one has been fetched
+		if r.URL.Query().Get("conid") == "1" {
+			close(started)
+			<-r.Context().Done()
+			return
+		}
+
+		handler(w, r)
+	}))
+	defer mockServer.Close()
+
+	cache, err := NewHistoryCache(t.TempDir())
+	assert.NoError(t, err)
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+	client.RetryPolicy = nil
+	client.HistoryCache = cache
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	end := time.Date(2023, 12, 11, 0, 0, 0, 0, time.UTC)
+	go func() {
+		_, err := client.GetMarketDataHistoryRangeCtx(ctx, 1, BarSize1Day, end.Add(-5*24*time.Hour), end, false)
+		done <- err
+	}()
+	<-started
+
+	bars, err := client.GetMarketDataHistoryRange(2, BarSize1Day, end.Add(-5*24*time.Hour), end, false)
+	assert.NoError(t, err)
+	assert.Len(t, bars, 5)
+
+	cancel()
+	assert.Error(t, <-done)
+}
+
+func TestIbkrWebClient_MarketDataHistoryCachedHead(t *testing.T) {
+	var requests []string
+	mockServer := httptest.NewServer(testDailyHistoryHandler(t, &requests))
+	defer mockServer.Close()
+
+	cache, err := NewHistoryCache(t.TempDir())
+	assert.NoError(t, err)
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+	client.HistoryCache = cache
+
+	end := time.Date(2023, 12, 11, 0, 0, 0, 0, time.UTC)
+	_, err = client.GetMarketDataHistoryRange(testConid, BarSize1Day, end.Add(-5*24*time.Hour), end, false)
+	assert.NoError(t, err)
+
+	// an earlier range is fetched up to the start of the cached range
+	bars, err := client.GetMarketDataHistoryRange(
+		testConid,
+		BarSize1Day,
+		end.Add(-20*24*time.Hour),
+		end.Add(-15*24*time.Hour),
+		false,
+	)
+	assert.NoError(t, err)
+	assert.Len(t, bars, 5)
+	assert.Equal(t, []string{"5d", "15d"}, requests)
+
+	bars, err = client.GetMarketDataHistoryRange(testConid, BarSize1Day, end.Add(-20*24*time.Hour), end, false)
+	assert.NoError(t, err)
+	assert.Len(t, bars, 20)
+	assert.Len(t, requests, 2)
+}
+
+func TestHistoryCache_Evict(t *testing.T) {
+	cache, err := NewHistoryCache(t.TempDir())
+	assert.NoError(t, err)
+
+	from := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	keys := []historyCacheKey{
+		{1, BarSize1Day, false},
+		{2, BarSize1Day, false},
+		{3, BarSize1Hour, true},
+	}
+
+	for i, key := range keys {
+		err = cache.store(key, &historyCacheEntry{ConID: key.conId, From: from, To: from.Add(time.Hour)})
+		assert.NoError(t, err)
+
+		used := time.Now().Add(-time.Duration(len(keys)-i) * time.Hour)
+		assert.NoError(t, os.Chtimes(cache.path(key), used, used))
+	}
+
+	// loading marks the oldest file as recently used
+	entry, err := cache.load(keys[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, entry.ConID)
+
+	cache.MaxFiles = 2
+	assert.NoError(t, cache.Evict())
+	assert.NoFileExists(t, cache.path(keys[1]))
+	assert.FileExists(t, cache.path(keys[0]))
+	assert.FileExists(t, cache.path(keys[2]))
+
+	cache.MaxAge = 30 * time.Minute
+	assert.NoError(t, cache.Evict())
+	assert.NoFileExists(t, cache.path(keys[2]))
+	assert.FileExists(t, cache.path(keys[0]))
+
+	assert.NoError(t, cache.Clear())
+	assert.NoFileExists(t, cache.path(keys[0]))
+
+	entry, err = cache.load(keys[0])
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+}
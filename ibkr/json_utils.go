@@ -0,0 +1,43 @@
+package ibkr
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// ibkr sends many numeric fields as strings, sometimes with thousands separators, and
+// sometimes as plain numbers depending on the endpoint. null and empty strings decode to 0.
+type flexFloat float64
+
+func (f *flexFloat) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*f = 0
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		err := json.Unmarshal(data, &value)
+		if err != nil {
+			return err
+		}
+
+		value = strings.ReplaceAll(strings.TrimSpace(value), ",", "")
+		if value == "" {
+			*f = 0
+			return nil
+		}
+
+		data = []byte(value)
+	}
+
+	parsed, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return err
+	}
+
+	*f = flexFloat(parsed)
+	return nil
+}
@@ -0,0 +1,32 @@
+package ibkr
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_flexFloat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{`1.5`, 1.5},
+		{`"1.5"`, 1.5},
+		{`"1,234,567.89"`, 1234567.89},
+		{`"-12"`, -12},
+		{`""`, 0},
+		{`null`, 0},
+	}
+
+	for _, tt := range tests {
+		var value flexFloat
+		err := json.Unmarshal([]byte(tt.input), &value)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, float64(value), tt.input)
+	}
+
+	var value flexFloat
+	assert.Error(t, json.Unmarshal([]byte(`"abc"`), &value))
+}
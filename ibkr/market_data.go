@@ -1,10 +1,18 @@
 package ibkr
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 /******************************************************************************
@@ -12,29 +20,39 @@ import (
 ******************************************************************************/
 
 type MarketDataHistoryResponse struct {
-	StartTime       string    `json:"startTime" validation:"required"`
-	Data            []OHLCBar `json:"data" validation:"required"`
-	Points          int       `json:"points" validation:"required"`
-	MarketDataDelay int       `json:"mktDataDelay" validation:"required"`
+	StartTime       string    `json:"startTime"`
+	Data            []OHLCBar `json:"data" validate:"dive"`
+	Points          int       `json:"points"`
+	MarketDataDelay int       `json:"mktDataDelay"`
 }
 
+// T is the bar open time in epoch milliseconds.
 type OHLCBar struct {
-	T int     `json:"t" validation:"required"`
-	O float64 `json:"o" validation:"required"`
-	C float64 `json:"c" validation:"required"`
-	H float64 `json:"h" validation:"required"`
-	L float64 `json:"l" validation:"required"`
-	V float64 `json:"v" validation:"required"`
+	T int     `json:"t" validate:"required"`
+	O float64 `json:"o"`
+	C float64 `json:"c"`
+	H float64 `json:"h"`
+	L float64 `json:"l"`
+	V float64 `json:"v"`
+}
+
+func (b OHLCBar) Time() time.Time {
+	return time.UnixMilli(int64(b.T)).UTC()
 }
 
 const (
+	// Deprecated: ibkr does not accept second periods, use MarketDataPeriodMinutes.
 	MarketDataPeriodSeconds = "S"
+	MarketDataPeriodMinutes = "min"
+	MarketDataPeriodHours   = "h"
 	MarketDataPeriodDay     = "d"
 	MarketDataPeriodWeek    = "w"
 	MarketDataPeriodMonth   = "m"
 	MarketDataPeriodYear    = "y"
 )
 
+// Deprecated: these units are not valid bar sizes on their own, use the BarSize constants such as
+// BarSize1Min or BarSize1Day.
 const (
 	MarketDataBarSeconds = "secs"
 	MarketDataBarMinutes = "mins"
@@ -44,24 +62,233 @@ const (
 	MarketDataBarMonth   = "m"
 )
 
+// the number of bars ibkr returns at most for one history request.
+const MaxHistoryPoints = 1000
+
+const historyStartTimeLayout = "20060102-15:04:05"
+
+// a history period such as "5d" or "2y", see NewPeriod.
+type Period string
+
+type periodUnit struct {
+	duration time.Duration
+	maxCount int
+	minBar   BarSize
+	maxBar   BarSize
+}
+
+// months and years are approximated with 30 and 365 days.
+var periodUnits = map[string]periodUnit{
+	MarketDataPeriodMinutes: {time.Minute, 30, BarSize1Min, BarSize8Hours},
+	MarketDataPeriodHours:   {time.Hour, 8, BarSize1Min, BarSize8Hours},
+	MarketDataPeriodDay:     {24 * time.Hour, 1000, BarSize1Min, BarSize1Month},
+	MarketDataPeriodWeek:    {7 * 24 * time.Hour, 792, BarSize10Min, BarSize1Month},
+	MarketDataPeriodMonth:   {30 * 24 * time.Hour, 182, BarSize1Hour, BarSize1Month},
+	MarketDataPeriodYear:    {365 * 24 * time.Hour, 15, BarSize1Day, BarSize1Month},
+}
+
+// ordered from the shortest unit to the longest.
+var periodUnitOrder = []string{
+	MarketDataPeriodMinutes,
+	MarketDataPeriodHours,
+	MarketDataPeriodDay,
+	MarketDataPeriodWeek,
+	MarketDataPeriodMonth,
+	MarketDataPeriodYear,
+}
+
+func NewPeriod(count int, unit string) Period {
+	return Period(fmt.Sprintf("%d%s", count, unit))
+}
+
+func (p Period) parse() (int, string, error) {
+	digits := strings.IndexFunc(string(p), func(r rune) bool { return r < '0' || r > '9' })
+	if digits <= 0 {
+		return 0, "", fmt.Errorf("invalid history period: %q", p)
+	}
+
+	count, err := strconv.Atoi(string(p)[:digits])
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid history period: %q", p)
+	}
+
+	unit := string(p)[digits:]
+	unitInfo, ok := periodUnits[unit]
+	if !ok {
+		return 0, "", fmt.Errorf("invalid history period unit: %q", p)
+	}
+
+	if count < 1 || count > unitInfo.maxCount {
+		return 0, "", fmt.Errorf("history period %q out of range, %s periods allow 1 to %d", p, unit, unitInfo.maxCount)
+	}
+
+	return count, unit, nil
+}
+
+func (p Period) Validate() error {
+	_, _, err := p.parse()
+	return err
+}
+
+func (p Period) Duration() time.Duration {
+	count, unit, err := p.parse()
+	if err != nil {
+		return 0
+	}
+
+	return time.Duration(count) * periodUnits[unit].duration
+}
+
+type BarSize string
+
+const (
+	BarSize1Min   BarSize = "1min"
+	BarSize2Min   BarSize = "2min"
+	BarSize3Min   BarSize = "3min"
+	BarSize5Min   BarSize = "5min"
+	BarSize10Min  BarSize = "10min"
+	BarSize15Min  BarSize = "15min"
+	BarSize30Min  BarSize = "30min"
+	BarSize1Hour  BarSize = "1h"
+	BarSize2Hours BarSize = "2h"
+	BarSize3Hours BarSize = "3h"
+	BarSize4Hours BarSize = "4h"
+	BarSize8Hours BarSize = "8h"
+	BarSize1Day   BarSize = "1d"
+	BarSize1Week  BarSize = "1w"
+	BarSize1Month BarSize = "1m"
+)
+
+var barSizeDurations = map[BarSize]time.Duration{
+	BarSize1Min:   time.Minute,
+	BarSize2Min:   2 * time.Minute,
+	BarSize3Min:   3 * time.Minute,
+	BarSize5Min:   5 * time.Minute,
+	BarSize10Min:  10 * time.Minute,
+	BarSize15Min:  15 * time.Minute,
+	BarSize30Min:  30 * time.Minute,
+	BarSize1Hour:  time.Hour,
+	BarSize2Hours: 2 * time.Hour,
+	BarSize3Hours: 3 * time.Hour,
+	BarSize4Hours: 4 * time.Hour,
+	BarSize8Hours: 8 * time.Hour,
+	BarSize1Day:   24 * time.Hour,
+	BarSize1Week:  7 * 24 * time.Hour,
+	BarSize1Month: 30 * 24 * time.Hour,
+}
+
+func (b BarSize) Validate() error {
+	_, ok := barSizeDurations[b]
+	if !ok {
+		return fmt.Errorf("invalid history bar size: %q", b)
+	}
+	return nil
+}
+
+// months are approximated with 30 days.
+func (b BarSize) Duration() time.Duration {
+	return barSizeDurations[b]
+}
+
+// checks the bar size is one ibkr allows for the period.
+func ValidateHistoryParams(period Period, bar BarSize) error {
+	_, unit, err := period.parse()
+	if err != nil {
+		return err
+	}
+
+	err = bar.Validate()
+	if err != nil {
+		return err
+	}
+
+	unitInfo := periodUnits[unit]
+	if bar.Duration() < unitInfo.minBar.Duration() || bar.Duration() > unitInfo.maxBar.Duration() {
+		return fmt.Errorf(
+			"bar size %s not allowed for period %s, allowed %s to %s",
+			bar,
+			period,
+			unitInfo.minBar,
+			unitInfo.maxBar,
+		)
+	}
+
+	if bar.Duration() > period.Duration() {
+		return fmt.Errorf("bar size %s is longer than period %s", bar, period)
+	}
+
+	return nil
+}
+
+// StartTime is the time the period counts back from and defaults to now.
+type MarketDataHistoryRequest struct {
+	ConID      int
+	Period     Period
+	Bar        BarSize
+	OutsideRth bool
+	StartTime  time.Time
+}
+
 func (c *IbkrWebClient) MarketDataHistory(
 	conId int,
-	period string,
-	barType string,
+	period Period,
+	bar BarSize,
+) (*MarketDataHistoryResponse, error) {
+	return c.MarketDataHistoryCtx(context.Background(), conId, period, bar)
+}
+
+func (c *IbkrWebClient) MarketDataHistoryCtx(
+	ctx context.Context,
+	conId int,
+	period Period,
+	bar BarSize,
+) (*MarketDataHistoryResponse, error) {
+	return c.GetMarketDataHistoryCtx(ctx, MarketDataHistoryRequest{ConID: conId, Period: period, Bar: bar})
+}
+
+func (c *IbkrWebClient) GetMarketDataHistory(request MarketDataHistoryRequest) (*MarketDataHistoryResponse, error) {
+	return c.GetMarketDataHistoryCtx(context.Background(), request)
+}
+
+func (c *IbkrWebClient) GetMarketDataHistoryCtx(
+	ctx context.Context,
+	request MarketDataHistoryRequest,
+) (*MarketDataHistoryResponse, error) {
+	err := ValidateHistoryParams(request.Period, request.Bar)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.HistoryCache != nil {
+		return c.cachedMarketDataHistory(ctx, request)
+	}
+
+	return c.marketDataHistory(ctx, request)
+}
+
+// requests one page of history from ibkr, the request must already be validated.
+func (c *IbkrWebClient) marketDataHistory(
+	ctx context.Context,
+	request MarketDataHistoryRequest,
 ) (*MarketDataHistoryResponse, error) {
 	params := map[string]string{
-		"conid":  strconv.Itoa(int(conId)),
-		"period": period,
-		"bar":    barType,
+		"conid":      strconv.Itoa(request.ConID),
+		"period":     string(request.Period),
+		"bar":        string(request.Bar),
+		"outsideRth": strconv.FormatBool(request.OutsideRth),
+	}
+
+	if !request.StartTime.IsZero() {
+		params["startTime"] = request.StartTime.UTC().Format(historyStartTimeLayout)
 	}
 
-	response, err := c.Get("/iserver/marketdata/history", params)
+	response, err := c.GetCtx(ctx, "/iserver/marketdata/history", params)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad market data history statusCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
 	var responseStruct MarketDataHistoryResponse
@@ -73,123 +300,701 @@ func (c *IbkrWebClient) MarketDataHistory(
 	return &responseStruct, nil
 }
 
+func (c *IbkrWebClient) GetMarketDataHistoryRange(
+	conId int,
+	bar BarSize,
+	from time.Time,
+	to time.Time,
+	outsideRth bool,
+) ([]OHLCBar, error) {
+	return c.GetMarketDataHistoryRangeCtx(context.Background(), conId, bar, from, to, outsideRth)
+}
+
+// fetches the bars opening in [from, to) by walking back from to with as many requests as needed
+// to stay under MaxHistoryPoints per request. bars are returned oldest first without duplicates.
+func (c *IbkrWebClient) GetMarketDataHistoryRangeCtx(
+	ctx context.Context,
+	conId int,
+	bar BarSize,
+	from time.Time,
+	to time.Time,
+	outsideRth bool,
+) ([]OHLCBar, error) {
+	err := bar.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if !from.Before(to) {
+		return nil, fmt.Errorf("history range start %v is not before end %v", from, to)
+	}
+
+	if c.HistoryCache != nil {
+		return c.cachedMarketDataHistoryRange(ctx, historyCacheKey{conId, bar, outsideRth}, from, to)
+	}
+
+	return c.marketDataHistoryRange(ctx, conId, bar, from, to, outsideRth)
+}
+
+func (c *IbkrWebClient) marketDataHistoryRange(
+	ctx context.Context,
+	conId int,
+	bar BarSize,
+	from time.Time,
+	to time.Time,
+	outsideRth bool,
+) ([]OHLCBar, error) {
+	var bars []OHLCBar
+	end := to
+	for end.After(from) {
+		span := min(end.Sub(from), bar.Duration()*MaxHistoryPoints)
+
+		period, err := historyPeriodForSpan(span, bar)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := c.marketDataHistory(ctx, MarketDataHistoryRequest{
+			ConID:      conId,
+			Period:     period,
+			Bar:        bar,
+			OutsideRth: outsideRth,
+			StartTime:  end,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		earliest := end
+		for _, b := range response.Data {
+			barTime := b.Time()
+			if barTime.Before(from) || !barTime.Before(to) {
+				continue
+			}
+
+			bars = append(bars, b)
+			if barTime.Before(earliest) {
+				earliest = barTime
+			}
+		}
+
+		// step back a full period when nothing new came back, e.g. over weekends and holidays
+		if !earliest.Before(end) {
+			earliest = end.Add(-period.Duration())
+		}
+		end = earliest
+	}
+
+	return mergeBars(bars), nil
+}
+
+// picks the shortest period ibkr allows for the bar size that covers span, or the longest one
+// when none do.
+func historyPeriodForSpan(span time.Duration, bar BarSize) (Period, error) {
+	var best Period
+	var bestCoverage time.Duration
+	for _, unit := range periodUnitOrder {
+		unitInfo := periodUnits[unit]
+
+		count := int((span + unitInfo.duration - 1) / unitInfo.duration)
+		count = min(max(count, 1), unitInfo.maxCount)
+
+		period := NewPeriod(count, unit)
+		if ValidateHistoryParams(period, bar) != nil {
+			continue
+		}
+
+		coverage := period.Duration()
+		switch {
+		case best == "",
+			bestCoverage < span && coverage > bestCoverage,
+			coverage >= span && coverage < bestCoverage:
+			best = period
+			bestCoverage = coverage
+		}
+	}
+
+	if best == "" {
+		return "", fmt.Errorf("no history period allows bar size %s", bar)
+	}
+
+	return best, nil
+}
+
+// sorts bars oldest first and drops bars with duplicate times, keeping the last one seen.
+func mergeBars(bars []OHLCBar) []OHLCBar {
+	byTime := make(map[int]OHLCBar, len(bars))
+	for _, b := range bars {
+		byTime[b.T] = b
+	}
+
+	merged := make([]OHLCBar, 0, len(byTime))
+	for _, b := range byTime {
+		merged = append(merged, b)
+	}
+
+	slices.SortFunc(merged, func(a, b OHLCBar) int { return a.T - b.T })
+
+	return merged
+}
+
 /******************************************************************************
 * market data snapshot
 ******************************************************************************/
 
 const (
-	snapshotFieldSymbol      = "55"
-	snapshotFieldLastPrice   = "31"
-	snapshotFieldHigh        = "70"
-	snapshotFieldLow         = "71"
-	snapshotFieldMarketValue = "73"
-	snapshotFieldOpen        = "7295"
-	snapshotFieldMark        = "7635"
-	snapshotFieldPriorClose  = "7741"
+	MarketDataFieldLastPrice               = "31"
+	MarketDataFieldSymbol                  = "55"
+	MarketDataFieldHigh                    = "70"
+	MarketDataFieldLow                     = "71"
+	MarketDataFieldMarketValue             = "73"
+	MarketDataFieldChange                  = "82"
+	MarketDataFieldChangePercent           = "83"
+	MarketDataFieldBid                     = "84"
+	MarketDataFieldAskSize                 = "85"
+	MarketDataFieldAsk                     = "86"
+	MarketDataFieldVolume                  = "87"
+	MarketDataFieldBidSize                 = "88"
+	MarketDataFieldHistoricalVolatility    = "7087"
+	MarketDataFieldAverageVolume           = "7282"
+	MarketDataFieldOptionImpliedVolatility = "7283"
+	MarketDataFieldDividendYield           = "7287"
+	MarketDataFieldMarketCap               = "7289"
+	MarketDataFieldPriceEarnings           = "7290"
+	MarketDataFieldEarningsPerShare        = "7291"
+	MarketDataField52WeekHigh              = "7293"
+	MarketDataField52WeekLow               = "7294"
+	MarketDataFieldOpen                    = "7295"
+	MarketDataFieldClose                   = "7296"
+	MarketDataFieldDelta                   = "7308"
+	MarketDataFieldGamma                   = "7309"
+	MarketDataFieldTheta                   = "7310"
+	MarketDataFieldVega                    = "7311"
+	MarketDataFieldImpliedVolatility       = "7633"
+	MarketDataFieldMark                    = "7635"
+	MarketDataFieldPriorClose              = "7741"
 )
 
+var MarketDataFieldNames = map[string]string{
+	MarketDataFieldLastPrice:               "last price",
+	MarketDataFieldSymbol:                  "symbol",
+	MarketDataFieldHigh:                    "high",
+	MarketDataFieldLow:                     "low",
+	MarketDataFieldMarketValue:             "market value",
+	MarketDataFieldChange:                  "change",
+	MarketDataFieldChangePercent:           "change percent",
+	MarketDataFieldBid:                     "bid",
+	MarketDataFieldAskSize:                 "ask size",
+	MarketDataFieldAsk:                     "ask",
+	MarketDataFieldVolume:                  "volume",
+	MarketDataFieldBidSize:                 "bid size",
+	MarketDataFieldHistoricalVolatility:    "historical volatility percent",
+	MarketDataFieldAverageVolume:           "average volume",
+	MarketDataFieldOptionImpliedVolatility: "option implied volatility percent",
+	MarketDataFieldDividendYield:           "dividend yield percent",
+	MarketDataFieldMarketCap:               "market cap",
+	MarketDataFieldPriceEarnings:           "price earnings ratio",
+	MarketDataFieldEarningsPerShare:        "earnings per share",
+	MarketDataField52WeekHigh:              "52 week high",
+	MarketDataField52WeekLow:               "52 week low",
+	MarketDataFieldOpen:                    "open",
+	MarketDataFieldClose:                   "close",
+	MarketDataFieldDelta:                   "delta",
+	MarketDataFieldGamma:                   "gamma",
+	MarketDataFieldTheta:                   "theta",
+	MarketDataFieldVega:                    "vega",
+	MarketDataFieldImpliedVolatility:       "implied volatility percent",
+	MarketDataFieldMark:                    "mark",
+	MarketDataFieldPriorClose:              "prior close",
+}
+
 var marketDataSnapshotFields = []string{
-	snapshotFieldSymbol,
-	snapshotFieldLastPrice,
-	snapshotFieldHigh,
-	snapshotFieldLow,
-	snapshotFieldMarketValue,
-	snapshotFieldOpen,
-	snapshotFieldMark,
-	snapshotFieldPriorClose,
-}
-
-type MarketDataSnapshotResponse struct {
-	ConID      int    `json:"conid" validation:"required"`
-	LastPrice  string `json:"31" validation:"required"`
-	High       string `json:"70" validation:"required"`
-	Low        string `json:"71" validation:"required"`
-	Open       string `json:"7295" validation:"required"`
-	Mark       string `json:"7635" validation:"required"`
-	PriorClose string `json:"7741" validation:"required"`
+	MarketDataFieldSymbol,
+	MarketDataFieldLastPrice,
+	MarketDataFieldHigh,
+	MarketDataFieldLow,
+	MarketDataFieldMarketValue,
+	MarketDataFieldOpen,
+	MarketDataFieldMark,
+	MarketDataFieldPriorClose,
 }
 
+// numeric fields are zero when they were not requested, ibkr did not return them or they failed
+// to parse. Fields holds the raw value of every field that was returned and FieldErrors the error
+// of each field that could not be read or, for MarketDataSnapshot, a missing last price.
 type MarketDataSnapshot struct {
-	ConID         int
-	TradingHalted bool
-	TradingActive bool
-	LastPrice     float64
+	ConID                   int
+	Updated                 time.Time
+	TradingHalted           bool
+	TradingActive           bool
+	Symbol                  string
+	LastPrice               float64
+	High                    float64
+	Low                     float64
+	Open                    float64
+	Close                   float64
+	Mark                    float64
+	PriorClose              float64
+	Bid                     float64
+	Ask                     float64
+	BidSize                 float64
+	AskSize                 float64
+	Volume                  float64
+	AverageVolume           float64
+	Change                  float64
+	ChangePercent           float64
+	MarketValue             float64
+	MarketCap               float64
+	DividendYield           float64
+	PriceEarnings           float64
+	EarningsPerShare        float64
+	High52Week              float64
+	Low52Week               float64
+	ImpliedVolatility       float64
+	OptionImpliedVolatility float64
+	HistoricalVolatility    float64
+	Delta                   float64
+	Gamma                   float64
+	Theta                   float64
+	Vega                    float64
+	Fields                  map[string]string
+	FieldErrors             map[string]error
+}
+
+var marketDataSnapshotFloatFields = map[string]func(s *MarketDataSnapshot) *float64{
+	MarketDataFieldLastPrice:               func(s *MarketDataSnapshot) *float64 { return &s.LastPrice },
+	MarketDataFieldHigh:                    func(s *MarketDataSnapshot) *float64 { return &s.High },
+	MarketDataFieldLow:                     func(s *MarketDataSnapshot) *float64 { return &s.Low },
+	MarketDataFieldMarketValue:             func(s *MarketDataSnapshot) *float64 { return &s.MarketValue },
+	MarketDataFieldChange:                  func(s *MarketDataSnapshot) *float64 { return &s.Change },
+	MarketDataFieldChangePercent:           func(s *MarketDataSnapshot) *float64 { return &s.ChangePercent },
+	MarketDataFieldBid:                     func(s *MarketDataSnapshot) *float64 { return &s.Bid },
+	MarketDataFieldAskSize:                 func(s *MarketDataSnapshot) *float64 { return &s.AskSize },
+	MarketDataFieldAsk:                     func(s *MarketDataSnapshot) *float64 { return &s.Ask },
+	MarketDataFieldVolume:                  func(s *MarketDataSnapshot) *float64 { return &s.Volume },
+	MarketDataFieldBidSize:                 func(s *MarketDataSnapshot) *float64 { return &s.BidSize },
+	MarketDataFieldHistoricalVolatility:    func(s *MarketDataSnapshot) *float64 { return &s.HistoricalVolatility },
+	MarketDataFieldAverageVolume:           func(s *MarketDataSnapshot) *float64 { return &s.AverageVolume },
+	MarketDataFieldOptionImpliedVolatility: func(s *MarketDataSnapshot) *float64 { return &s.OptionImpliedVolatility },
+	MarketDataFieldDividendYield:           func(s *MarketDataSnapshot) *float64 { return &s.DividendYield },
+	MarketDataFieldMarketCap:               func(s *MarketDataSnapshot) *float64 { return &s.MarketCap },
+	MarketDataFieldPriceEarnings:           func(s *MarketDataSnapshot) *float64 { return &s.PriceEarnings },
+	MarketDataFieldEarningsPerShare:        func(s *MarketDataSnapshot) *float64 { return &s.EarningsPerShare },
+	MarketDataField52WeekHigh:              func(s *MarketDataSnapshot) *float64 { return &s.High52Week },
+	MarketDataField52WeekLow:               func(s *MarketDataSnapshot) *float64 { return &s.Low52Week },
+	MarketDataFieldOpen:                    func(s *MarketDataSnapshot) *float64 { return &s.Open },
+	MarketDataFieldClose:                   func(s *MarketDataSnapshot) *float64 { return &s.Close },
+	MarketDataFieldDelta:                   func(s *MarketDataSnapshot) *float64 { return &s.Delta },
+	MarketDataFieldGamma:                   func(s *MarketDataSnapshot) *float64 { return &s.Gamma },
+	MarketDataFieldTheta:                   func(s *MarketDataSnapshot) *float64 { return &s.Theta },
+	MarketDataFieldVega:                    func(s *MarketDataSnapshot) *float64 { return &s.Vega },
+	MarketDataFieldImpliedVolatility:       func(s *MarketDataSnapshot) *float64 { return &s.ImpliedVolatility },
+	MarketDataFieldMark:                    func(s *MarketDataSnapshot) *float64 { return &s.Mark },
+	MarketDataFieldPriorClose:              func(s *MarketDataSnapshot) *float64 { return &s.PriorClose },
 }
 
 func (c *IbkrWebClient) MarketDataSnapshot(
 	conIds []int,
 ) ([]MarketDataSnapshot, error) {
-	conIdParam := ""
-	for i, conid := range conIds {
-		if i == 0 {
-			conIdParam = conIdParam + strconv.Itoa(conid)
-		} else {
-			conIdParam = conIdParam + "," + strconv.Itoa(conid)
+	return c.MarketDataSnapshotCtx(context.Background(), conIds)
+}
+
+// conids without a last price yet are kept with an error wrapping ErrSnapshotIncomplete in
+// FieldErrors, MarketDataSnapshotWarmUp polls until the data is available.
+func (c *IbkrWebClient) MarketDataSnapshotCtx(
+	ctx context.Context,
+	conIds []int,
+) ([]MarketDataSnapshot, error) {
+	snapshots, err := c.MarketDataSnapshotFieldsCtx(ctx, conIds, marketDataSnapshotFields...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range snapshots {
+		_, ok := snapshots[i].Fields[MarketDataFieldLastPrice]
+		if !ok {
+			snapshots[i].FieldErrors[MarketDataFieldLastPrice] = fmt.Errorf(
+				"%w: no last price returned for conid %v",
+				ErrSnapshotIncomplete,
+				snapshots[i].ConID,
+			)
 		}
 	}
 
-	fieldsParam := ""
-	for i, field := range marketDataSnapshotFields {
-		if i == 0 {
-			fieldsParam = fieldsParam + field
-		} else {
-			fieldsParam = fieldsParam + "," + field
+	return snapshots, nil
+}
+
+func (c *IbkrWebClient) MarketDataSnapshotFields(
+	conIds []int,
+	fields ...string,
+) ([]MarketDataSnapshot, error) {
+	return c.MarketDataSnapshotFieldsCtx(context.Background(), conIds, fields...)
+}
+
+// requests the given MarketDataField values for each conid. long conid lists are split into
+// requests of at most MaxSnapshotConIds. a field that fails to parse is recorded in the
+// snapshot's FieldErrors without failing the other fields or conids.
+func (c *IbkrWebClient) MarketDataSnapshotFieldsCtx(
+	ctx context.Context,
+	conIds []int,
+	fields ...string,
+) ([]MarketDataSnapshot, error) {
+	if len(conIds) == 0 || len(fields) == 0 {
+		return nil, fmt.Errorf("market data snapshot requires conids and fields")
+	}
+
+	snapshots := []MarketDataSnapshot{}
+	for _, chunk := range chunkConIds(conIds, MaxSnapshotConIds) {
+		responseStruct, err := c.marketDataSnapshotRaw(ctx, chunk, fields)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, raw := range responseStruct {
+			snapshot, err := parseMarketDataSnapshot(raw)
+			if snapshot == nil {
+				if c.VerboseLogging {
+					log.Printf("---- ibkr market data snapshot entry skipped: %v", err)
+				}
+				continue
+			}
+
+			snapshots = append(snapshots, *snapshot)
 		}
 	}
 
+	return snapshots, nil
+}
+
+func chunkConIds(conIds []int, size int) [][]int {
+	if size <= 0 {
+		return [][]int{conIds}
+	}
+
+	var chunks [][]int
+	for len(conIds) > size {
+		chunks = append(chunks, conIds[:size])
+		conIds = conIds[size:]
+	}
+	return append(chunks, conIds)
+}
+
+func (c *IbkrWebClient) marketDataSnapshotRaw(
+	ctx context.Context,
+	conIds []int,
+	fields []string,
+) ([]map[string]json.RawMessage, error) {
+	conIdStrings := make([]string, len(conIds))
+	for i, conid := range conIds {
+		conIdStrings[i] = strconv.Itoa(conid)
+	}
+
 	params := map[string]string{
-		"conids": conIdParam,
-		"fields": fieldsParam,
+		"conids": strings.Join(conIdStrings, ","),
+		"fields": strings.Join(fields, ","),
 	}
 
-	response, err := c.Get("/iserver/marketdata/snapshot", params)
+	response, err := c.GetCtx(ctx, "/iserver/marketdata/snapshot", params)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad market data history statusCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
-	var responseStruct []MarketDataSnapshotResponse
-	err = c.ParseJsonResponse(response, &responseStruct)
+	// the response is keyed by field id, so it is decoded generically rather than into a struct
+	var responseStruct []map[string]json.RawMessage
+	err = json.Unmarshal(response.bytes, &responseStruct)
 	if err != nil {
 		return nil, err
 	}
 
-	snapshots := []MarketDataSnapshot{}
-	for _, raw := range responseStruct {
+	return responseStruct, nil
+}
+
+/******************************************************************************
+* market data snapshot warm up
+******************************************************************************/
+
+var MaxSnapshotConIds = 100
+
+var (
+	DefaultSnapshotWarmUpTimeout = 5 * time.Second
+	DefaultSnapshotPollInterval  = 500 * time.Millisecond
+)
+
+// Fields defaults to the fields requested by MarketDataSnapshot. RequiredFields are the fields
+// that must be populated before a conid is complete and default to all of Fields.
+type SnapshotOptions struct {
+	Fields         []string
+	RequiredFields []string
+	WarmUpTimeout  time.Duration
+	PollInterval   time.Duration
+}
+
+// Snapshot holds the latest data received for the conid, which may be partial when Err is set.
+type SnapshotResult struct {
+	ConID    int
+	Snapshot *MarketDataSnapshot
+	Err      error
+}
+
+func (c *IbkrWebClient) MarketDataSnapshotWarmUp(conIds []int, options SnapshotOptions) ([]SnapshotResult, error) {
+	return c.MarketDataSnapshotWarmUpCtx(context.Background(), conIds, options)
+}
+
+// ibkr starts streaming a conid on its first snapshot request and only returns price fields on
+// later requests, so conids are polled until their required fields are populated or the warm up
+// times out. results are returned per conid in the order given, and the error is only set when the
+// options are invalid or the context ends.
+func (c *IbkrWebClient) MarketDataSnapshotWarmUpCtx(
+	ctx context.Context,
+	conIds []int,
+	options SnapshotOptions,
+) ([]SnapshotResult, error) {
+	if len(conIds) == 0 {
+		return nil, fmt.Errorf("market data snapshot requires conids")
+	}
+
+	if len(options.Fields) == 0 {
+		options.Fields = marketDataSnapshotFields
+	}
+	if options.RequiredFields == nil {
+		options.RequiredFields = options.Fields
+	}
+	if options.WarmUpTimeout <= 0 {
+		options.WarmUpTimeout = DefaultSnapshotWarmUpTimeout
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultSnapshotPollInterval
+	}
+
+	var uniqueConIds []int
+	results := map[int]*SnapshotResult{}
+	for _, conId := range conIds {
+		if results[conId] == nil {
+			results[conId] = &SnapshotResult{ConID: conId}
+			uniqueConIds = append(uniqueConIds, conId)
+		}
+	}
+
+	for _, chunk := range chunkConIds(uniqueConIds, MaxSnapshotConIds) {
+		err := c.warmUpSnapshotChunk(ctx, chunk, &options, results)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ordered := make([]SnapshotResult, len(uniqueConIds))
+	for i, conId := range uniqueConIds {
+		ordered[i] = *results[conId]
+	}
+
+	return ordered, nil
+}
+
+func (c *IbkrWebClient) warmUpSnapshotChunk(
+	ctx context.Context,
+	conIds []int,
+	options *SnapshotOptions,
+	results map[int]*SnapshotResult,
+) error {
+	deadline := time.Now().Add(options.WarmUpTimeout)
+	pending := conIds
+
+	for {
+		responseStruct, err := c.marketDataSnapshotRaw(ctx, pending, options.Fields)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		for _, conId := range pending {
+			results[conId].Err = err
+			if err == nil {
+				results[conId].Err = fmt.Errorf("%w: no data returned for conid %d", ErrSnapshotIncomplete, conId)
+			}
+		}
+
+		for _, raw := range responseStruct {
+			snapshot, err := parseMarketDataSnapshot(raw)
+			if snapshot == nil {
+				if c.VerboseLogging {
+					log.Printf("---- ibkr market data snapshot entry skipped: %v", err)
+				}
+				continue
+			}
 
-		lastPriceString := raw.LastPrice
-		var lastPricePrefix string
-		if strings.HasPrefix(lastPriceString, "C") || strings.HasPrefix(lastPriceString, "H") {
-			lastPricePrefix = lastPriceString[:1]
-			lastPriceString = lastPriceString[1:]
+			result, ok := results[snapshot.ConID]
+			if !ok {
+				continue
+			}
+
+			if err != nil {
+				result.Err = err
+				continue
+			}
+
+			result.Snapshot = snapshot
+			result.Err = nil
+
+			var missing []string
+			for _, field := range options.RequiredFields {
+				_, ok := snapshot.Fields[field]
+				if !ok {
+					missing = append(missing, field)
+				}
+			}
+
+			if len(missing) > 0 {
+				result.Err = fmt.Errorf("%w: conid %d missing fields %v", ErrSnapshotIncomplete, snapshot.ConID, missing)
+			}
+		}
+
+		var stillPending []int
+		for _, conId := range pending {
+			if results[conId].Err != nil {
+				stillPending = append(stillPending, conId)
+			}
+		}
+		pending = stillPending
+
+		if len(pending) == 0 || time.Now().Add(options.PollInterval).After(deadline) {
+			return nil
 		}
 
-		tradingActive := true
-		tradingHalted := false
-		if lastPricePrefix == "C" {
-			tradingActive = false
-		} else if lastPricePrefix == "H" {
-			tradingActive = false
-			tradingHalted = true
+		timer := time.NewTimer(options.PollInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
 		}
+	}
+}
+
+// when fields fail to parse the snapshot is still returned, with the errors recorded in
+// FieldErrors and joined into the returned error. placeholders such as "N/A" or "-" in numeric
+// fields are treated as absent.
+func parseMarketDataSnapshot(raw map[string]json.RawMessage) (*MarketDataSnapshot, error) {
+	snapshot := MarketDataSnapshot{
+		TradingActive: true,
+		Fields:        map[string]string{},
+		FieldErrors:   map[string]error{},
+	}
 
-		lastPriceFloat, err := strconv.ParseFloat(lastPriceString, 64)
+	err := json.Unmarshal(raw["conid"], &snapshot.ConID)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing market data snapshot conid: %w", err)
+	}
+
+	var updated int64
+	if json.Unmarshal(raw["_updated"], &updated) == nil && updated > 0 {
+		snapshot.Updated = time.UnixMilli(updated)
+	}
+
+	// field ids are numeric, everything else is metadata
+	var fields []string
+	for field := range raw {
+		_, err := strconv.Atoi(field)
+		if err == nil {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var fieldErrors []error
+	for _, field := range fields {
+		var stringValue string
+		if json.Unmarshal(raw[field], &stringValue) != nil {
+			stringValue = string(raw[field])
+		}
+
+		if stringValue == "" {
+			continue
+		}
+
+		if field == MarketDataFieldSymbol {
+			snapshot.Fields[field] = stringValue
+			snapshot.Symbol = stringValue
+			continue
+		}
+
+		dest, ok := marketDataSnapshotFloatFields[field]
+		if ok && isMarketDataPlaceholder(stringValue) {
+			continue
+		}
+		snapshot.Fields[field] = stringValue
+
+		if !ok {
+			continue
+		}
+
+		parsed, prefix, err := parseMarketDataNumber(stringValue)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing last price for conid %v, found: %v", raw.ConID, raw.LastPrice)
+			err = fmt.Errorf(
+				"error parsing %s for conid %v, found: %v",
+				MarketDataFieldNames[field],
+				snapshot.ConID,
+				stringValue,
+			)
+			snapshot.FieldErrors[field] = err
+			fieldErrors = append(fieldErrors, err)
+			continue
 		}
+		*dest(&snapshot) = parsed
 
-		snapshot := MarketDataSnapshot{
-			ConID:         raw.ConID,
-			TradingActive: tradingActive,
-			TradingHalted: tradingHalted,
-			LastPrice:     lastPriceFloat,
+		if field == MarketDataFieldLastPrice {
+			snapshot.TradingActive = prefix == ""
+			snapshot.TradingHalted = prefix == marketDataPrefixHalted
 		}
+	}
 
-		snapshots = append(snapshots, snapshot)
+	return &snapshot, errors.Join(fieldErrors...)
+}
+
+// ibkr sends values such as "N/A" or "-" when a numeric field has no data.
+func isMarketDataPlaceholder(value string) bool {
+	return !strings.ContainsFunc(value, unicode.IsDigit)
+}
+
+const (
+	marketDataPrefixClosed = "C"
+	marketDataPrefixHalted = "H"
+)
+
+// parses ibkr formatted numbers. prices may be prefixed with C when the value is the prior close
+// or H when trading is halted, and sizes may carry K, M, B or T suffixes.
+func parseMarketDataNumber(value string) (float64, string, error) {
+	value = strings.TrimSpace(value)
+
+	prefix := ""
+	if strings.HasPrefix(value, marketDataPrefixClosed) || strings.HasPrefix(value, marketDataPrefixHalted) {
+		prefix = value[:1]
+		value = value[1:]
 	}
 
-	return snapshots, nil
+	value = strings.TrimSuffix(strings.ReplaceAll(value, ",", ""), "%")
+
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1e3
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1e6
+	case strings.HasSuffix(value, "B"):
+		multiplier = 1e9
+	case strings.HasSuffix(value, "T"):
+		multiplier = 1e12
+	}
+	if multiplier != 1 {
+		value = value[:len(value)-1]
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, prefix, err
+	}
+
+	return parsed * multiplier, prefix, nil
 }
@@ -1,10 +1,12 @@
 package ibkr
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -62,6 +64,109 @@ func TestIbkrWebClient_MarketDataHistory(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestIbkrWebClient_GetMarketDataHistory(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1234", r.URL.Query().Get("conid"))
+		assert.Equal(t, "5d", r.URL.Query().Get("period"))
+		assert.Equal(t, "1h", r.URL.Query().Get("bar"))
+		assert.Equal(t, "true", r.URL.Query().Get("outsideRth"))
+		assert.Equal(t, "20231211-09:30:00", r.URL.Query().Get("startTime"))
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, testMarketDataHistoryResponse)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	history, err := client.GetMarketDataHistory(MarketDataHistoryRequest{
+		ConID:      testConid,
+		Period:     NewPeriod(5, MarketDataPeriodDay),
+		Bar:        BarSize1Hour,
+		OutsideRth: true,
+		StartTime:  time.Date(2023, 12, 11, 4, 30, 0, 0, time.FixedZone("EST", -5*60*60)),
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, history.Data, 1)
+	assert.Equal(t, time.Date(2023, 12, 11, 9, 0, 0, 0, time.UTC), history.Data[0].Time())
+	assert.Equal(t, 1723.0, history.Data[0].V)
+}
+
+func TestValidateHistoryParams(t *testing.T) {
+	tests := []struct {
+		period Period
+		bar    BarSize
+		valid  bool
+	}{
+		{"30min", BarSize1Min, true},
+		{"8h", BarSize1Hour, true},
+		{"1000d", BarSize1Day, true},
+		{"2y", BarSize1Day, true},
+		{"6m", BarSize1Hour, true},
+		{"31min", BarSize1Min, false},
+		{"0d", BarSize1Day, false},
+		{"16y", BarSize1Day, false},
+		{"1y", BarSize1Hour, false},
+		{"1w", BarSize5Min, false},
+		{"1d", BarSize1Week, false},
+		{"1d", "5mins", false},
+		{"d", BarSize1Day, false},
+		{"5s", BarSize1Min, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateHistoryParams(tt.period, tt.bar)
+		assert.Equal(t, tt.valid, err == nil, "%s %s: %v", tt.period, tt.bar, err)
+	}
+
+	client := NewIbkrWebClient("http://localhost", &MockOAuthContext{})
+	_, err := client.MarketDataHistory(testConid, "1y", "1min")
+	assert.Error(t, err)
+}
+
+func TestIbkrWebClient_GetMarketDataHistoryRange(t *testing.T) {
+	from := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(1500 * time.Hour)
+
+	var periods []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		periods = append(periods, r.URL.Query().Get("period"))
+
+		end, err := time.Parse(historyStartTimeLayout, r.URL.Query().Get("startTime"))
+		assert.NoError(t, err)
+
+		period := Period(r.URL.Query().Get("period"))
+
+		// hourly bars covering the period, with one overlapping the previous request
+		var bars []OHLCBar
+		for barTime := end.Add(-period.Duration()); !barTime.After(end); barTime = barTime.Add(time.Hour) {
+			bars = append(bars, OHLCBar{T: int(barTime.UnixMilli()), C: 1})
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(MarketDataHistoryResponse{Data: bars, Points: len(bars)})
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+
+	bars, err := client.GetMarketDataHistoryRange(testConid, BarSize1Hour, from, to, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"42d", "21d"}, periods)
+	assert.Len(t, bars, 1500)
+	assert.Equal(t, from, bars[0].Time())
+	assert.Equal(t, to.Add(-time.Hour), bars[len(bars)-1].Time())
+
+	for i := 1; i < len(bars); i++ {
+		assert.Equal(t, time.Hour, bars[i].Time().Sub(bars[i-1].Time()))
+	}
+
+	_, err = client.GetMarketDataHistoryRange(testConid, BarSize1Hour, to, from, false)
+	assert.Error(t, err)
+}
+
 func TestIbkrWebClient_MarketDataSnapshot(t *testing.T) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -76,3 +181,223 @@ func TestIbkrWebClient_MarketDataSnapshot(t *testing.T) {
 	assert.NotNil(t, history)
 	assert.NoError(t, err)
 }
+
+func TestIbkrWebClient_MarketDataSnapshotMissingLastPrice(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `[{"conid": 265598, "31": "192.26"}, {"conid": 8314, "55": "IBM"}, {"conid": 9999, "31": "1.2.3"}]`)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	snapshots, err := client.MarketDataSnapshot([]int{265598, 8314, 9999})
+
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 3)
+
+	assert.Empty(t, snapshots[0].FieldErrors)
+	assert.Equal(t, 192.26, snapshots[0].LastPrice)
+
+	assert.Equal(t, 8314, snapshots[1].ConID)
+	assert.Equal(t, "IBM", snapshots[1].Symbol)
+	assert.ErrorIs(t, snapshots[1].FieldErrors[MarketDataFieldLastPrice], ErrSnapshotIncomplete)
+
+	assert.Equal(t, 9999, snapshots[2].ConID)
+	assert.ErrorContains(t, snapshots[2].FieldErrors[MarketDataFieldLastPrice], "last price")
+}
+
+var testMarketDataSnapshotFieldsResponse = `
+[
+  {
+    "_updated": 1702334859712,
+    "conid": 265598,
+    "55": "AAPL",
+    "31": "C192.26",
+    "70": "194.40",
+    "71": "191.09",
+    "84": "192.25",
+    "85": "1,200",
+    "86": "192.27",
+    "87": "45.2M",
+    "83": "-0.54%",
+    "7282": "55.1M",
+    "7293": "199.62",
+    "7308": "0.512",
+    "7741": "193.18"
+  },
+  {
+    "conid": 8314,
+    "31": "H141.10",
+    "87": "2.5K"
+  }
+]`
+
+func TestIbkrWebClient_MarketDataSnapshotFields(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "265598,8314", r.URL.Query().Get("conids"))
+		assert.Equal(t, "31,84,87", r.URL.Query().Get("fields"))
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, testMarketDataSnapshotFieldsResponse)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	snapshots, err := client.MarketDataSnapshotFields(
+		[]int{265598, 8314},
+		MarketDataFieldLastPrice,
+		MarketDataFieldBid,
+		MarketDataFieldVolume,
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+
+	snapshot := snapshots[0]
+	assert.Equal(t, 265598, snapshot.ConID)
+	assert.Equal(t, time.UnixMilli(1702334859712), snapshot.Updated)
+	assert.Equal(t, "AAPL", snapshot.Symbol)
+	assert.Equal(t, 192.26, snapshot.LastPrice)
+	assert.False(t, snapshot.TradingActive)
+	assert.False(t, snapshot.TradingHalted)
+	assert.Equal(t, 194.40, snapshot.High)
+	assert.Equal(t, 191.09, snapshot.Low)
+	assert.Equal(t, 192.25, snapshot.Bid)
+	assert.Equal(t, 192.27, snapshot.Ask)
+	assert.Equal(t, 1200.0, snapshot.AskSize)
+	assert.InDelta(t, 45.2e6, snapshot.Volume, 1e-6)
+	assert.InDelta(t, 55.1e6, snapshot.AverageVolume, 1e-6)
+	assert.Equal(t, -0.54, snapshot.ChangePercent)
+	assert.Equal(t, 199.62, snapshot.High52Week)
+	assert.Equal(t, 0.512, snapshot.Delta)
+	assert.Equal(t, 193.18, snapshot.PriorClose)
+	assert.Equal(t, "C192.26", snapshot.Fields[MarketDataFieldLastPrice])
+
+	snapshot = snapshots[1]
+	assert.Equal(t, 141.10, snapshot.LastPrice)
+	assert.True(t, snapshot.TradingHalted)
+	assert.Equal(t, 2500.0, snapshot.Volume)
+}
+
+func TestIbkrWebClient_MarketDataSnapshotFieldsInvalid(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `[{"conid": 265598, "31": "1.2.3", "84": "192.25"}, {"conid": 8314, "31": "N/A", "84": "-"}]`)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+
+	// a field that fails to parse is recorded without failing the rest of the batch
+	snapshots, err := client.MarketDataSnapshotFields([]int{265598, 8314}, MarketDataFieldLastPrice, MarketDataFieldBid)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+
+	assert.ErrorContains(t, snapshots[0].FieldErrors[MarketDataFieldLastPrice], "last price")
+	assert.Zero(t, snapshots[0].LastPrice)
+	assert.Equal(t, 192.25, snapshots[0].Bid)
+
+	// placeholders are treated as absent
+	assert.Empty(t, snapshots[1].FieldErrors)
+	assert.Empty(t, snapshots[1].Fields)
+
+	_, err = client.MarketDataSnapshotFields([]int{265598})
+	assert.Error(t, err)
+}
+
+func Test_parseMarketDataNumber(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+		prefix   string
+	}{
+		{"192.26", 192.26, ""},
+		{"C192.26", 192.26, "C"},
+		{"H192.26", 192.26, "H"},
+		{"1.2K", 1200, ""},
+		{"3.5M", 3.5e6, ""},
+		{"2.9T", 2.9e12, ""},
+		{"1,234", 1234, ""},
+		{"-1.5%", -1.5, ""},
+	}
+
+	for _, tt := range tests {
+		value, prefix, err := parseMarketDataNumber(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.InDelta(t, tt.expected, value, 1e-9, tt.input)
+		assert.Equal(t, tt.prefix, prefix, tt.input)
+	}
+
+	_, _, err := parseMarketDataNumber("")
+	assert.Error(t, err)
+}
+
+func TestIbkrWebClient_MarketDataSnapshotWarmUp(t *testing.T) {
+	requests := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		conids := r.URL.Query().Get("conids")
+
+		w.WriteHeader(http.StatusOK)
+		switch {
+		case requests == 1:
+			assert.Equal(t, "265598,8314,9999", conids)
+			io.WriteString(w, `[{"conid": 265598}, {"conid": 8314}, {"conid": 9999, "31": "1.2.3"}]`)
+		case requests == 2:
+			assert.Equal(t, "265598,8314,9999", conids)
+			io.WriteString(w, `[{"conid": 265598, "31": "192.26", "84": "192.25"}, {"conid": 8314, "84": "141.0"}, {"conid": 9999, "31": "1.2.3"}]`)
+		default:
+			assert.Equal(t, "8314,9999", conids)
+			io.WriteString(w, `[{"conid": 8314, "84": "141.0"}, {"conid": 9999, "31": "1.2.3"}]`)
+		}
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+
+	results, err := client.MarketDataSnapshotWarmUp([]int{265598, 8314, 265598, 9999}, SnapshotOptions{
+		Fields:         []string{MarketDataFieldLastPrice, MarketDataFieldBid},
+		RequiredFields: []string{MarketDataFieldLastPrice},
+		WarmUpTimeout:  100 * time.Millisecond,
+		PollInterval:   10 * time.Millisecond,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+
+	assert.Equal(t, 265598, results[0].ConID)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, 192.26, results[0].Snapshot.LastPrice)
+
+	assert.Equal(t, 8314, results[1].ConID)
+	assert.ErrorIs(t, results[1].Err, ErrSnapshotIncomplete)
+	assert.Equal(t, 141.0, results[1].Snapshot.Bid)
+
+	assert.Equal(t, 9999, results[2].ConID)
+	assert.ErrorContains(t, results[2].Err, "last price")
+	assert.Greater(t, requests, 2)
+}
+
+func TestIbkrWebClient_MarketDataSnapshotFieldsChunked(t *testing.T) {
+	defer func(max int) { MaxSnapshotConIds = max }(MaxSnapshotConIds)
+	MaxSnapshotConIds = 2
+
+	var requested []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Query().Get("conids"))
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `[{"conid": 1, "31": "1.0"}]`)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+
+	snapshots, err := client.MarketDataSnapshotFields([]int{1, 2, 3, 4, 5}, MarketDataFieldLastPrice)
+
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 3)
+	assert.Equal(t, []string{"1,2", "3,4", "5"}, requested)
+}
@@ -1,6 +1,8 @@
 package ibkr
 
 import (
+	"bytes"
+	"context"
 	"crypto/dsa"
 	"crypto/hmac"
 	"crypto/rand"
@@ -18,6 +20,8 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -32,13 +36,20 @@ type OAuthContext interface {
 	Reset()
 }
 
+// optionally implemented by an OAuthContext so the live session token handshake can be cancelled.
+type OAuthContextCtx interface {
+	GenerateLiveSessionTokenCtx(ctx context.Context, client *http.Client, baseUrl string) error
+}
+
 type IbkrOAuthCredentials struct {
-	CustomerKey       string `yaml:"customer_key"`
-	AccessToken       string `yaml:"access_token"`
-	AccessSecret      string `yaml:"access_secret"`
-	SigningKeyPath    string `yaml:"signing_key_path"`
-	EncryptionKeyPath string `yaml:"encryption_key_path"`
-	DHParamsPath      string `yaml:"dh_params_path"`
+	CustomerKey             string `yaml:"customer_key"`
+	AccessToken             string `yaml:"access_token"`
+	AccessSecret            string `yaml:"access_secret"`
+	SigningKeyPath          string `yaml:"signing_key_path"`
+	SigningKeyPassphrase    string `yaml:"signing_key_passphrase"`
+	EncryptionKeyPath       string `yaml:"encryption_key_path"`
+	EncryptionKeyPassphrase string `yaml:"encryption_key_passphrase"`
+	DHParamsPath            string `yaml:"dh_params_path"`
 }
 
 type IbkrOAuthContext struct {
@@ -51,6 +62,10 @@ type IbkrOAuthContext struct {
 	LstExpiration  int64
 	Lst            string
 	VerboseLogging bool
+	// when set a live session token signature mismatch is logged instead of failing the handshake
+	LenientLstVerification bool
+	TokenStore             TokenStore
+	mu                     sync.RWMutex
 }
 
 type liveSessionTokenResponse struct {
@@ -82,6 +97,43 @@ func NewIbkrOAuthContext(
 		return nil, err
 	}
 
+	return newIbkrOAuthContext(consumerKey, accessToken, accessSecret, signingKey, encryptionKey, dhParams), nil
+}
+
+func NewIbkrOAuthContextFromPem(
+	consumerKey string,
+	accessToken string,
+	accessSecret string,
+	signingKeyPem []byte,
+	encryptionKeyPem []byte,
+	dhParamsPem []byte,
+) (*IbkrOAuthContext, error) {
+	signingKey, err := ParseRsaKeyPem(signingKeyPem)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing signing key: %w", err)
+	}
+
+	encryptionKey, err := ParseRsaKeyPem(encryptionKeyPem)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing encryption key: %w", err)
+	}
+
+	dhParams, err := ParseDhParametersPem(dhParamsPem)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing dh params: %w", err)
+	}
+
+	return newIbkrOAuthContext(consumerKey, accessToken, accessSecret, signingKey, encryptionKey, dhParams), nil
+}
+
+func newIbkrOAuthContext(
+	consumerKey string,
+	accessToken string,
+	accessSecret string,
+	signingKey *rsa.PrivateKey,
+	encryptionKey *rsa.PrivateKey,
+	dhParams *dsa.Parameters,
+) *IbkrOAuthContext {
 	return &IbkrOAuthContext{
 		ConsumerKey:    consumerKey,
 		SigningKey:     signingKey,
@@ -90,7 +142,7 @@ func NewIbkrOAuthContext(
 		AccessToken:    accessToken,
 		AccessSecret:   accessSecret,
 		VerboseLogging: false,
-	}, nil
+	}
 }
 
 func NewIbkrOAuthContextFromFile(credentialsFilePath string) (*IbkrOAuthContext, error) {
@@ -106,14 +158,126 @@ func NewIbkrOAuthContextFromFile(credentialsFilePath string) (*IbkrOAuthContext,
 		return nil, err
 	}
 
-	return NewIbkrOAuthContext(
-		credentials.CustomerKey,
-		credentials.AccessToken,
-		credentials.AccessSecret,
-		credentials.SigningKeyPath,
-		credentials.EncryptionKeyPath,
-		credentials.DHParamsPath,
-	)
+	return NewIbkrOAuthContextFromCredentials(credentials)
+}
+
+// each credential may be a secret reference such as "env:IBKR_ACCESS_TOKEN". plain values are
+// used as is, and plain key paths are read from disk.
+func NewIbkrOAuthContextFromCredentials(credentials IbkrOAuthCredentials) (*IbkrOAuthContext, error) {
+	consumerKey, err := resolveCredential("customer_key", credentials.CustomerKey, "")
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := resolveCredential("access_token", credentials.AccessToken, "")
+	if err != nil {
+		return nil, err
+	}
+
+	accessSecret, err := resolveCredential("access_secret", credentials.AccessSecret, "")
+	if err != nil {
+		return nil, err
+	}
+
+	signingKeyPem, err := resolveCredential("signing_key_path", credentials.SigningKeyPath, "file")
+	if err != nil {
+		return nil, err
+	}
+
+	encryptionKeyPem, err := resolveCredential("encryption_key_path", credentials.EncryptionKeyPath, "file")
+	if err != nil {
+		return nil, err
+	}
+
+	dhParamsPem, err := resolveCredential("dh_params_path", credentials.DHParamsPath, "file")
+	if err != nil {
+		return nil, err
+	}
+
+	signingKeyPassphrase, err := resolveCredential("signing_key_passphrase", credentials.SigningKeyPassphrase, "")
+	if err != nil {
+		return nil, err
+	}
+
+	encryptionKeyPassphrase, err := resolveCredential("encryption_key_passphrase", credentials.EncryptionKeyPassphrase, "")
+	if err != nil {
+		return nil, err
+	}
+
+	signingKey, err := ParseEncryptedRsaKeyPem(signingKeyPem, trimTrailingNewline(signingKeyPassphrase))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing signing key: %w", err)
+	}
+
+	encryptionKey, err := ParseEncryptedRsaKeyPem(encryptionKeyPem, trimTrailingNewline(encryptionKeyPassphrase))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing encryption key: %w", err)
+	}
+
+	dhParams, err := ParseDhParametersPem(dhParamsPem)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing dh params: %w", err)
+	}
+
+	return newIbkrOAuthContext(
+		strings.TrimSpace(string(consumerKey)),
+		strings.TrimSpace(string(accessToken)),
+		strings.TrimSpace(string(accessSecret)),
+		signingKey,
+		encryptionKey,
+		dhParams,
+	), nil
+}
+
+func resolveCredential(name string, ref string, defaultScheme string) ([]byte, error) {
+	value, err := ResolveSecret(ref, defaultScheme)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %s: %w", name, err)
+	}
+
+	return value, nil
+}
+
+// secret files and env vars usually end in a newline, any other whitespace belongs to the passphrase.
+func trimTrailingNewline(value []byte) []byte {
+	value = bytes.TrimSuffix(value, []byte("\n"))
+	return bytes.TrimSuffix(value, []byte("\r"))
+}
+
+const (
+	EnvConsumerKey      = "IBKR_CONSUMER_KEY"
+	EnvAccessToken      = "IBKR_ACCESS_TOKEN"
+	EnvAccessSecret     = "IBKR_ACCESS_SECRET"
+	EnvSigningKeyPem    = "IBKR_SIGNING_KEY_PEM"
+	EnvEncryptionKeyPem = "IBKR_ENCRYPTION_KEY_PEM"
+	EnvDhParamsPem      = "IBKR_DH_PARAMS_PEM"
+
+	EnvSigningKeyPassphrase    = "IBKR_SIGNING_KEY_PASSPHRASE"
+	EnvEncryptionKeyPassphrase = "IBKR_ENCRYPTION_KEY_PASSPHRASE"
+)
+
+func NewIbkrOAuthContextFromEnv() (*IbkrOAuthContext, error) {
+	credentials := IbkrOAuthCredentials{
+		CustomerKey:       "env:" + EnvConsumerKey,
+		AccessToken:       "env:" + EnvAccessToken,
+		AccessSecret:      "env:" + EnvAccessSecret,
+		SigningKeyPath:    "env:" + EnvSigningKeyPem,
+		EncryptionKeyPath: "env:" + EnvEncryptionKeyPem,
+		DHParamsPath:      "env:" + EnvDhParamsPem,
+	}
+
+	// passphrases are only needed for encrypted keys
+	_, ok := os.LookupEnv(EnvSigningKeyPassphrase)
+	if ok {
+		credentials.SigningKeyPassphrase = "env:" + EnvSigningKeyPassphrase
+	}
+
+	_, ok = os.LookupEnv(EnvEncryptionKeyPassphrase)
+	if ok {
+		credentials.EncryptionKeyPassphrase = "env:" + EnvEncryptionKeyPassphrase
+	}
+
+	return NewIbkrOAuthContextFromCredentials(credentials)
 }
 
 func generateNonce(bitLength int) (*big.Int, error) {
@@ -145,11 +309,16 @@ func getOAuthTimestamp() string {
 }
 
 func (i *IbkrOAuthContext) GetOAuthHeader(method string, requestUrl string) (string, error) {
-	if i.Lst == "" {
+	i.mu.RLock()
+	lst := i.Lst
+	lstExpiration := i.LstExpiration
+	i.mu.RUnlock()
+
+	if lst == "" {
 		return "", fmt.Errorf("ibkr oauth live session token not present")
 	}
 
-	if i.LstExpiration < time.Now().Unix() {
+	if lstExpiration < time.Now().Unix() {
 		return "", fmt.Errorf("ibker oauth live session token likely expired")
 	}
 
@@ -178,7 +347,7 @@ func (i *IbkrOAuthContext) GetOAuthHeader(method string, requestUrl string) (str
 		log.Printf("oauth header base string: %v", baseString)
 	}
 
-	tokenBytes, err := base64.StdEncoding.DecodeString(i.Lst)
+	tokenBytes, err := base64.StdEncoding.DecodeString(lst)
 	if err != nil {
 		return "", err
 	}
@@ -193,6 +362,20 @@ func (i *IbkrOAuthContext) GetOAuthHeader(method string, requestUrl string) (str
 }
 
 func (i *IbkrOAuthContext) GenerateLiveSessionToken(client *http.Client, baseUrl string) error {
+	return i.GenerateLiveSessionTokenCtx(context.Background(), client, baseUrl)
+}
+
+func (i *IbkrOAuthContext) GenerateLiveSessionTokenCtx(ctx context.Context, client *http.Client, baseUrl string) error {
+	restored, err := i.RestoreLiveSessionToken()
+	if err != nil {
+		if i.VerboseLogging {
+			log.Printf("error restoring stored live session token: %v", err)
+		}
+	}
+	if restored {
+		return nil
+	}
+
 	dhRandom, err := generateNonce(256)
 	if err != nil {
 		return err
@@ -244,7 +427,7 @@ func (i *IbkrOAuthContext) GenerateLiveSessionToken(client *http.Client, baseUrl
 	params["oauth_signature"] = url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
 	params["realm"] = "limited_poa"
 
-	req, err := http.NewRequest(methodPost, tokenUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, methodPost, tokenUrl, nil)
 	if err != nil {
 		return err
 	}
@@ -262,55 +445,115 @@ func (i *IbkrOAuthContext) GenerateLiveSessionToken(client *http.Client, baseUrl
 
 	logResponse(rsp, i.VerboseLogging)
 
-	if rsp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad live session token statusCode: %v", rsp.StatusCode)
-	}
-
 	body, err := io.ReadAll(rsp.Body)
 	if err != nil {
 		return err
 	}
 
+	if rsp.StatusCode != http.StatusOK {
+		return newAPIError(methodPost, "/oauth/live_session_token", rsp.StatusCode, body)
+	}
+
 	var lstRsp liveSessionTokenResponse
 	err = json.Unmarshal(body, &lstRsp)
 	if err != nil {
 		return err
 	}
 
-	dhResponse := new(big.Int)
-	dhResponse.SetString(lstRsp.DhResponse, 16)
+	dhResponse, ok := new(big.Int).SetString(lstRsp.DhResponse, 16)
+	if !ok {
+		return fmt.Errorf("invalid diffie hellman response: %v", lstRsp.DhResponse)
+	}
 
+	lstBytes := computeLiveSessionToken(dhResponse, dhRandom, i.DhParams.P, prepend)
+
+	if !verifyLiveSessionToken(lstBytes, i.ConsumerKey, lstRsp.LstSignature) {
+		if !i.LenientLstVerification {
+			return ErrLiveSessionTokenSignature
+		}
+		if i.VerboseLogging {
+			log.Printf("ibkr live session token signature mismatch, received: %v", lstRsp.LstSignature)
+		}
+	}
+
+	i.mu.Lock()
+	i.Lst = base64.StdEncoding.EncodeToString(lstBytes)
 	i.LstExpiration = lstRsp.LstExpiration
-	// lstSignature := lstRsp.LstSignature
+	i.mu.Unlock()
+
+	if i.TokenStore != nil {
+		err = i.TokenStore.Save(StoredToken{
+			ConsumerKey:   i.ConsumerKey,
+			AccessToken:   i.AccessToken,
+			Lst:           base64.StdEncoding.EncodeToString(lstBytes),
+			LstExpiration: lstRsp.LstExpiration,
+		})
+		if err != nil {
+			if i.VerboseLogging {
+				log.Printf("error saving live session token: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
 
-	kBig := new(big.Int)
-	kBig.Exp(dhResponse, dhRandom, i.DhParams.P)
-	kBytes := kBig.Bytes()
+// loads a previously saved live session token from the token store if one exists for these
+// credentials and it is not within LstExpirationThreshold of expiring.
+func (i *IbkrOAuthContext) RestoreLiveSessionToken() (bool, error) {
+	if i.TokenStore == nil {
+		return false, nil
+	}
 
-	hCalc := hmac.New(sha1.New, kBytes)
-	hCalc.Write(prepend)
-	lstBytes := hCalc.Sum(nil)
+	token, err := i.TokenStore.Load()
+	if err != nil || token == nil {
+		return false, err
+	}
 
-	i.Lst = base64.StdEncoding.EncodeToString(lstBytes)
+	if token.ConsumerKey != i.ConsumerKey || token.AccessToken != i.AccessToken || token.Lst == "" {
+		return false, nil
+	}
 
-	// for some reason the verification can sometimes fail with the provided signature.
-	// in all cases if the system proceeds and ignores verification, the lst is still accepted by ibkr, so
-	// it looks like it may be an issue with the api?????? need to check into this
+	if token.LstExpiration-time.Now().Unix() < LstExpirationThreshold {
+		return false, nil
+	}
 
-	// hVerify := hmac.New(sha1.New, lstBytes)
-	// hVerify.Write([]byte(i.ConsumerKey))
+	i.mu.Lock()
+	i.Lst = token.Lst
+	i.LstExpiration = token.LstExpiration
+	i.mu.Unlock()
 
-	// verifyBytes := hVerify.Sum(nil)
-	// verify := hex.EncodeToString(verifyBytes) //base64.StdEncoding.EncodeToString(verifyBytes)
+	return true, nil
+}
 
-	// if verify != lstSignature {
-	// 	fmt.Printf("lst signature mismatch. calc: %v, received: %v", verify, lstSignature)
-	// }
+// ibkr derives the token from K encoded as a two's complement byte array (java's
+// BigInteger.toByteArray), so a leading zero byte is required when the high bit is set.
+func toTwosComplementBytes(k *big.Int) []byte {
+	kBytes := k.Bytes()
+	if len(kBytes) == 0 || kBytes[0]&0x80 != 0 {
+		return append([]byte{0}, kBytes...)
+	}
+	return kBytes
+}
 
-	return nil
+func computeLiveSessionToken(dhResponse *big.Int, dhRandom *big.Int, prime *big.Int, prepend []byte) []byte {
+	k := new(big.Int).Exp(dhResponse, dhRandom, prime)
+
+	h := hmac.New(sha1.New, toTwosComplementBytes(k))
+	h.Write(prepend)
+	return h.Sum(nil)
+}
+
+func verifyLiveSessionToken(lst []byte, consumerKey string, signature string) bool {
+	h := hmac.New(sha1.New, lst)
+	h.Write([]byte(consumerKey))
+	return hmac.Equal([]byte(hex.EncodeToString(h.Sum(nil))), []byte(signature))
 }
 
 func (i *IbkrOAuthContext) ShouldReAuthenticate() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	if i.Lst == "" || i.LstExpiration <= 0 {
 		return true
 	}
@@ -321,6 +564,17 @@ func (i *IbkrOAuthContext) ShouldReAuthenticate() bool {
 }
 
 func (i *IbkrOAuthContext) Reset() {
+	i.mu.Lock()
 	i.Lst = ""
 	i.LstExpiration = 0
+	i.mu.Unlock()
+
+	if i.TokenStore != nil {
+		err := i.TokenStore.Clear()
+		if err != nil {
+			if i.VerboseLogging {
+				log.Printf("error clearing stored live session token: %v", err)
+			}
+		}
+	}
 }
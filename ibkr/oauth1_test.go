@@ -0,0 +1,176 @@
+package ibkr
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testLstPrime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 521), big.NewInt(1))
+var testLstPrepend, _ = hex.DecodeString("a1b2c3d4e5f60718293a4b5c6d7e8f90")
+
+func Test_toTwosComplementBytes(t *testing.T) {
+	assert.Equal(t, []byte{0x7f}, toTwosComplementBytes(big.NewInt(0x7f)))
+	assert.Equal(t, []byte{0x00, 0x80}, toTwosComplementBytes(big.NewInt(0x80)))
+	assert.Equal(t, []byte{0x01, 0x00}, toTwosComplementBytes(big.NewInt(0x100)))
+	assert.Equal(t, []byte{0x00}, toTwosComplementBytes(big.NewInt(0)))
+}
+
+func Test_computeLiveSessionToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		dhResponse int64
+		lst        string
+		signature  string
+	}{
+		{
+			name:       "high bit clear",
+			dhResponse: 3,
+			lst:        "a657b13633ea2f67dc53bf3c3fa3274f140fd64c",
+			signature:  "5e2386c787bddd805a1fbcb8af609d39a59fd08f",
+		},
+		{
+			name:       "high bit set",
+			dhResponse: 5,
+			lst:        "5c88b92cad99e129ebd91f6579384db4e8468cb1",
+			signature:  "ca8ffc68db36a0efb385f90e02d8a14532cfee60",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lst := computeLiveSessionToken(
+				big.NewInt(tt.dhResponse),
+				big.NewInt(0x1234567),
+				testLstPrime,
+				testLstPrepend,
+			)
+
+			assert.Equal(t, tt.lst, hex.EncodeToString(lst))
+			assert.True(t, verifyLiveSessionToken(lst, "TESTCONS", tt.signature))
+			assert.False(t, verifyLiveSessionToken(lst, "OTHERCONS", tt.signature))
+		})
+	}
+}
+
+func parseTestOAuthHeader(header string) map[string]string {
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, "OAuth "), ", ") {
+		key, value, _ := strings.Cut(part, "=")
+		params[key] = strings.Trim(value, "\"")
+	}
+	return params
+}
+
+// stands in for the ibkr live session token endpoint, deriving the token from the client's
+// diffie hellman challenge exactly as ibkr does. signatureOverride replaces the signature.
+func newTestLstServer(t *testing.T, oauth *IbkrOAuthContext, prepend []byte, signatureOverride string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/api/oauth/live_session_token", r.URL.Path)
+
+		params := parseTestOAuthHeader(r.Header.Get("Authorization"))
+		challenge, ok := new(big.Int).SetString(params["diffie_hellman_challenge"], 16)
+		assert.True(t, ok)
+
+		serverRandom, err := generateNonce(256)
+		assert.NoError(t, err)
+
+		dhResponse := new(big.Int).Exp(oauth.DhParams.G, serverRandom, oauth.DhParams.P)
+		lst := computeLiveSessionToken(challenge, serverRandom, oauth.DhParams.P, prepend)
+
+		h := hmac.New(sha1.New, lst)
+		h.Write([]byte(oauth.ConsumerKey))
+		signature := hex.EncodeToString(h.Sum(nil))
+		if signatureOverride != "" {
+			signature = signatureOverride
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(
+			w,
+			`{"diffie_hellman_response": "%s", "live_session_token_signature": "%s", "live_session_token_expiration": %d}`,
+			dhResponse.Text(16),
+			signature,
+			time.Now().Add(24*time.Hour).Unix(),
+		)
+	}))
+}
+
+func newTestIbkrOAuthContext(t *testing.T, prepend []byte) *IbkrOAuthContext {
+	keyFile, err := os.CreateTemp(".", "testfile")
+	assert.NoError(t, err)
+	defer os.Remove(keyFile.Name())
+	keyFile.Write([]byte(testRsaPemContents))
+	keyFile.Close()
+
+	dhFile, err := os.CreateTemp(".", "testfile")
+	assert.NoError(t, err)
+	defer os.Remove(dhFile.Name())
+	dhFile.Write([]byte(testDhParamsContents))
+	dhFile.Close()
+
+	key, err := ImportRsaKeyFromPem(keyFile.Name())
+	assert.NoError(t, err)
+
+	accessSecret, err := rsa.EncryptPKCS1v15(rand.Reader, &key.PublicKey, prepend)
+	assert.NoError(t, err)
+
+	oauth, err := NewIbkrOAuthContext(
+		"TESTCONS",
+		"testtoken",
+		base64.StdEncoding.EncodeToString(accessSecret),
+		keyFile.Name(),
+		keyFile.Name(),
+		dhFile.Name(),
+	)
+	assert.NoError(t, err)
+
+	return oauth
+}
+
+func TestIbkrOAuthContext_GenerateLiveSessionToken(t *testing.T) {
+	oauth := newTestIbkrOAuthContext(t, testLstPrepend)
+
+	// repeat the handshake so both leading byte cases of K are exercised
+	for i := 0; i < 8; i++ {
+		mockServer := newTestLstServer(t, oauth, testLstPrepend, "")
+
+		oauth.Reset()
+		err := oauth.GenerateLiveSessionToken(mockServer.Client(), mockServer.URL)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, oauth.Lst)
+		assert.False(t, oauth.ShouldReAuthenticate())
+
+		mockServer.Close()
+	}
+}
+
+func TestIbkrOAuthContext_GenerateLiveSessionTokenBadSignature(t *testing.T) {
+	oauth := newTestIbkrOAuthContext(t, testLstPrepend)
+
+	mockServer := newTestLstServer(t, oauth, testLstPrepend, "deadbeef")
+	defer mockServer.Close()
+
+	err := oauth.GenerateLiveSessionToken(mockServer.Client(), mockServer.URL)
+	assert.ErrorIs(t, err, ErrLiveSessionTokenSignature)
+	assert.Empty(t, oauth.Lst)
+
+	oauth.LenientLstVerification = true
+
+	err = oauth.GenerateLiveSessionToken(mockServer.Client(), mockServer.URL)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, oauth.Lst)
+}
@@ -0,0 +1,64 @@
+package ibkr
+
+// identifies a precautionary message in the MessageIdDescriptions catalog.
+type MessageID string
+
+// ids of the precautionary messages ibkr asks to confirm when placing or modifying orders. any of
+// them can be answered through an OrderReplyPolicy or suppressed with SuppressMessageIDs. the
+// constants are untyped so they can be used both as plain strings and as a MessageID.
+const (
+	MessageIdPricePercentageConstraint = "o163"
+	MessageIdOrderWithoutMarketData    = "o354"
+	MessageIdTickSizeLimit             = "o382"
+	MessageIdSizeLimit                 = "o383"
+	MessageIdImmediateFill             = "o403"
+	MessageIdTotalValueLimit           = "o451"
+	MessageIdMixedAllocation           = "o2136"
+	MessageIdCrossSideOrder            = "o2137"
+	MessageIdFractionalOutsideRth      = "o2165"
+	MessageIdCalledBond                = "o10082"
+	MessageIdSizeModificationLimit     = "o10138"
+	MessageIdMarketOrderRisk           = "o10151"
+	MessageIdStopOrderRisk             = "o10152"
+	MessageIdMandatoryCapPrice         = "o10153"
+	MessageIdCashQuantityDetails       = "o10164"
+	MessageIdCashQuantityOrder         = "o10223"
+	MessageIdCryptoMarketOrderRisk     = "o10288"
+	MessageIdStopOrderTypes            = "o10331"
+	MessageIdDigitalCurrencyOrder      = "o10332"
+	MessageIdOptionExerciseAtTheMoney  = "o10333"
+	MessageIdOmnibusAccount            = "o10334"
+	MessageIdRapidEntry                = "o10335"
+	MessageIdLimitedLiquidity          = "o10336"
+)
+
+var MessageIdDescriptions = map[MessageID]string{
+	MessageIdPricePercentageConstraint: "order price exceeds the percentage constraint from the current market price",
+	MessageIdOrderWithoutMarketData:    "order submitted without market data for the instrument",
+	MessageIdTickSizeLimit:             "order value exceeds the tick size limit",
+	MessageIdSizeLimit:                 "order size exceeds the size limit",
+	MessageIdImmediateFill:             "order will most likely trigger and fill immediately",
+	MessageIdTotalValueLimit:           "order value estimate exceeds the total value limit",
+	MessageIdMixedAllocation:           "mixed allocation order warning",
+	MessageIdCrossSideOrder:            "cross side order warning",
+	MessageIdFractionalOutsideRth:      "instrument does not support fractional trading outside regular trading hours",
+	MessageIdCalledBond:                "called bond warning",
+	MessageIdSizeModificationLimit:     "order size modification exceeds the size modification limit",
+	MessageIdMarketOrderRisk:           "risks associated with market orders",
+	MessageIdStopOrderRisk:             "risks associated with stop orders once they become active",
+	MessageIdMandatoryCapPrice:         "confirm the mandatory cap price",
+	MessageIdCashQuantityDetails:       "cash quantity details are provided on a best efforts basis",
+	MessageIdCashQuantityOrder:         "cash quantity order confirmation",
+	MessageIdCryptoMarketOrderRisk:     "risks associated with market orders for crypto",
+	MessageIdStopOrderTypes:            "stop order types available and the risks associated with each",
+	MessageIdDigitalCurrencyOrder:      "digital currency order warning",
+	MessageIdOptionExerciseAtTheMoney:  "option exercise at the money warning",
+	MessageIdOmnibusAccount:            "order will be placed into the current omnibus account",
+	MessageIdRapidEntry:                "rapid entry window confirmation",
+	MessageIdLimitedLiquidity:          "security has limited liquidity",
+}
+
+// returns an empty string for ids not in the catalog.
+func DescribeMessageID(messageId MessageID) string {
+	return MessageIdDescriptions[messageId]
+}
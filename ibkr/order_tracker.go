@@ -0,0 +1,257 @@
+package ibkr
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+var DefaultOrderTrackerInterval = 5 * time.Second
+
+type OrderEventType int
+
+const (
+	OrderPartiallyFilled OrderEventType = iota
+	OrderFilled
+	OrderCancelled
+	OrderRejected
+	OrderInactive
+)
+
+func (t OrderEventType) String() string {
+	switch t {
+	case OrderPartiallyFilled:
+		return "partially filled"
+	case OrderFilled:
+		return "filled"
+	case OrderCancelled:
+		return "cancelled"
+	case OrderRejected:
+		return "rejected"
+	case OrderInactive:
+		return "inactive"
+	default:
+		return "unknown"
+	}
+}
+
+// FilledDelta is the quantity filled since the previous event for the order.
+type OrderEvent struct {
+	Type        OrderEventType
+	Order       OrderStatus
+	FilledDelta float64
+	Time        time.Time
+}
+
+type orderPhase int
+
+const (
+	orderPhaseWorking orderPhase = iota
+	orderPhaseFilled
+	orderPhaseCancelled
+	orderPhaseRejected
+	orderPhaseInactive
+)
+
+// ibkr also reports orders held outside trading hours or staged as inactive, so inactive orders may
+// still become active and are not treated as rejected.
+func orderPhaseFromStatus(status string) orderPhase {
+	switch status {
+	case "Filled":
+		return orderPhaseFilled
+	case "Cancelled", "ApiCancelled":
+		return orderPhaseCancelled
+	case "Rejected":
+		return orderPhaseRejected
+	case "Inactive":
+		return orderPhaseInactive
+	default:
+		return orderPhaseWorking
+	}
+}
+
+func (p orderPhase) terminal() bool {
+	return p == orderPhaseFilled || p == orderPhaseCancelled || p == orderPhaseRejected
+}
+
+var orderPhaseEvents = map[orderPhase]OrderEventType{
+	orderPhaseFilled:    OrderFilled,
+	orderPhaseCancelled: OrderCancelled,
+	orderPhaseRejected:  OrderRejected,
+	orderPhaseInactive:  OrderInactive,
+}
+
+// polls live orders and publishes fills and state changes. orders already live when the tracker
+// starts are picked up without events for their existing fills. ibkr often answers the first orders
+// request of a session with an empty list, so the tracker only starts publishing after a non-empty
+// response or its second poll. orders that drop off the live orders list publish no event and are
+// forgotten once a non-empty poll no longer lists them, so Order only reports listed orders.
+type OrderTracker struct {
+	Interval time.Duration
+	client   *IbkrWebClient
+	events   chan OrderEvent
+	mu       sync.Mutex
+	orders   map[int32]OrderStatus
+	seeded   bool
+	polls    int
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// the interval is raised to the documented rate limit of the live orders endpoint if it is shorter.
+func NewOrderTracker(client *IbkrWebClient, interval time.Duration) *OrderTracker {
+	if interval <= 0 {
+		interval = DefaultOrderTrackerInterval
+	}
+
+	limit, ok := IbkrEndpointRateLimits["/iserver/account/orders"]
+	if ok && limit.Requests > 0 {
+		interval = max(interval, limit.Per/time.Duration(limit.Requests))
+	}
+
+	return &OrderTracker{
+		Interval: interval,
+		client:   client,
+		events:   make(chan OrderEvent, 64),
+		orders:   map[int32]OrderStatus{},
+	}
+}
+
+// order events are published here and the tracker waits for the consumer rather than dropping
+// them. the channel is closed when the tracker is stopped.
+func (t *OrderTracker) Events() <-chan OrderEvent {
+	return t.events
+}
+
+func (t *OrderTracker) Order(orderId int32) (OrderStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	order, ok := t.orders[orderId]
+	return order, ok
+}
+
+func (t *OrderTracker) Start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.done = make(chan struct{})
+
+	go t.run(ctx)
+}
+
+// stops polling and closes the events channel. a stopped tracker cannot be restarted.
+func (t *OrderTracker) Stop() {
+	t.mu.Lock()
+	cancel := t.cancel
+	done := t.done
+	t.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+func (t *OrderTracker) run(ctx context.Context) {
+	defer close(t.done)
+	defer close(t.events)
+
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		t.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *OrderTracker) poll(ctx context.Context) {
+	rsp, err := t.client.GetLiveOrdersCtx(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			if t.client.VerboseLogging {
+				log.Printf("---- ibkr order tracker failed to fetch live orders: %v", err)
+			}
+		}
+		return
+	}
+
+	for _, event := range t.update(rsp.Orders) {
+		select {
+		case t.events <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *OrderTracker) update(orders []OrderStatus) []OrderEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	seeding := !t.seeded
+	t.polls++
+	t.seeded = len(orders) > 0 || t.polls >= 2
+
+	var events []OrderEvent
+	listed := make(map[int32]bool, len(orders))
+	for _, order := range orders {
+		listed[order.OrderID] = true
+
+		previous, known := t.orders[order.OrderID]
+		t.orders[order.OrderID] = order
+
+		if seeding {
+			continue
+		}
+
+		previousPhase := orderPhaseWorking
+		if known {
+			previousPhase = orderPhaseFromStatus(previous.Status)
+		}
+
+		// terminal orders keep being listed for the rest of the day
+		if previousPhase.terminal() {
+			continue
+		}
+
+		filledDelta := order.FilledQuantity - previous.FilledQuantity
+		phase := orderPhaseFromStatus(order.Status)
+
+		eventType, ok := orderPhaseEvents[phase]
+		switch {
+		case ok && phase != previousPhase:
+			events = append(events, OrderEvent{Type: eventType, Order: order, FilledDelta: filledDelta, Time: now})
+		case filledDelta > 0:
+			events = append(events, OrderEvent{Type: OrderPartiallyFilled, Order: order, FilledDelta: filledDelta, Time: now})
+		}
+	}
+
+	// an empty list may be the stale first response of a session, so only a non-empty list is
+	// trusted to drop orders
+	if len(orders) > 0 {
+		for orderId := range t.orders {
+			if !listed[orderId] {
+				delete(t.orders, orderId)
+			}
+		}
+	}
+
+	return events
+}
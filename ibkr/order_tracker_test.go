@@ -0,0 +1,171 @@
+package ibkr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testTrackedOrder(orderId int32, status string, filled float64, remaining float64) OrderStatus {
+	return OrderStatus{
+		Account:           "U1234567",
+		ConID:             265598,
+		OrderID:           orderId,
+		Ticker:            "AAPL",
+		Status:            status,
+		FilledQuantity:    filled,
+		RemainingQuantity: remaining,
+	}
+}
+
+func TestNewOrderTracker_RespectsRateLimit(t *testing.T) {
+	tracker := NewOrderTracker(NewIbkrWebClient("mockurl", &MockOAuthContext{}), time.Second)
+	assert.Equal(t, 5*time.Second, tracker.Interval)
+}
+
+func TestOrderTracker_update(t *testing.T) {
+	tracker := NewOrderTracker(nil, 0)
+
+	events := tracker.update([]OrderStatus{
+		testTrackedOrder(1, "Submitted", 0, 10),
+		testTrackedOrder(2, "Filled", 5, 0),
+	})
+	assert.Empty(t, events)
+
+	events = tracker.update([]OrderStatus{
+		testTrackedOrder(1, "Submitted", 4, 6),
+		testTrackedOrder(2, "Filled", 5, 0),
+		testTrackedOrder(3, "Rejected", 0, 1),
+		testTrackedOrder(5, "Inactive", 0, 3),
+	})
+	assert.Len(t, events, 3)
+	assert.Equal(t, OrderPartiallyFilled, events[0].Type)
+	assert.Equal(t, 4.0, events[0].FilledDelta)
+	assert.Equal(t, OrderRejected, events[1].Type)
+	assert.Equal(t, int32(3), events[1].Order.OrderID)
+	assert.Equal(t, OrderInactive, events[2].Type)
+
+	events = tracker.update([]OrderStatus{
+		testTrackedOrder(1, "Filled", 10, 0),
+		testTrackedOrder(3, "Rejected", 0, 1),
+		testTrackedOrder(4, "Cancelled", 0, 2),
+		testTrackedOrder(5, "Inactive", 0, 3),
+	})
+	assert.Len(t, events, 2)
+	assert.Equal(t, OrderFilled, events[0].Type)
+	assert.Equal(t, 6.0, events[0].FilledDelta)
+	assert.Equal(t, OrderCancelled, events[1].Type)
+
+	// inactive orders held outside trading hours can still become active and fill
+	events = tracker.update([]OrderStatus{
+		testTrackedOrder(1, "Filled", 10, 0),
+		testTrackedOrder(5, "Submitted", 1, 2),
+	})
+	assert.Len(t, events, 1)
+	assert.Equal(t, OrderPartiallyFilled, events[0].Type)
+
+	events = tracker.update([]OrderStatus{testTrackedOrder(5, "Filled", 3, 0)})
+	assert.Len(t, events, 1)
+	assert.Equal(t, OrderFilled, events[0].Type)
+
+	order, ok := tracker.Order(5)
+	assert.True(t, ok)
+	assert.Equal(t, "Filled", order.Status)
+}
+
+func TestOrderTracker_updateForgetsUnlistedOrders(t *testing.T) {
+	tracker := NewOrderTracker(nil, 0)
+
+	tracker.update([]OrderStatus{
+		testTrackedOrder(1, "Submitted", 0, 5),
+		testTrackedOrder(2, "Submitted", 0, 5),
+	})
+
+	events := tracker.update([]OrderStatus{
+		testTrackedOrder(1, "Filled", 5, 0),
+		testTrackedOrder(2, "Submitted", 0, 5),
+	})
+	assert.Len(t, events, 1)
+
+	// an empty list is not trusted to drop orders
+	tracker.update(nil)
+	_, ok := tracker.Order(1)
+	assert.True(t, ok)
+
+	events = tracker.update([]OrderStatus{testTrackedOrder(2, "Cancelled", 0, 5)})
+	assert.Len(t, events, 1)
+	assert.Len(t, tracker.orders, 1)
+
+	_, ok = tracker.Order(1)
+	assert.False(t, ok)
+
+	order, ok := tracker.Order(2)
+	assert.True(t, ok)
+	assert.Equal(t, "Cancelled", order.Status)
+}
+
+func TestOrderTracker_updateEmptyFirstPoll(t *testing.T) {
+	tracker := NewOrderTracker(nil, 0)
+
+	// the first orders request of a session often comes back empty
+	events := tracker.update(nil)
+	assert.Empty(t, events)
+
+	events = tracker.update([]OrderStatus{
+		testTrackedOrder(1, "Filled", 5, 0),
+		testTrackedOrder(2, "Submitted", 1, 4),
+	})
+	assert.Empty(t, events)
+
+	events = tracker.update([]OrderStatus{
+		testTrackedOrder(1, "Filled", 5, 0),
+		testTrackedOrder(2, "Filled", 5, 0),
+	})
+	assert.Len(t, events, 1)
+	assert.Equal(t, int32(2), events[0].Order.OrderID)
+
+	// an empty tracker still publishes new orders once it has polled twice
+	tracker = NewOrderTracker(nil, 0)
+	tracker.update(nil)
+	tracker.update(nil)
+
+	events = tracker.update([]OrderStatus{testTrackedOrder(3, "Filled", 1, 0)})
+	assert.Len(t, events, 1)
+}
+
+func TestOrderTracker_PublishesEvents(t *testing.T) {
+	var polls atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/api/iserver/account/orders", r.URL.Path)
+
+		order := testTrackedOrder(1, "Submitted", 0, 5)
+		if polls.Add(1) > 1 {
+			order = testTrackedOrder(1, "Filled", 5, 0)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(LiveOrdersResponse{Orders: []OrderStatus{order}})
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+
+	tracker := NewOrderTracker(client, 0)
+	tracker.Interval = 10 * time.Millisecond
+	tracker.Start()
+
+	event := <-tracker.Events()
+	assert.Equal(t, OrderFilled, event.Type)
+	assert.Equal(t, 5.0, event.FilledDelta)
+
+	tracker.Stop()
+
+	_, open := <-tracker.Events()
+	assert.False(t, open)
+}
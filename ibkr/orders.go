@@ -1,21 +1,171 @@
 package ibkr
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 /******************************************************************************
 * place order
 ******************************************************************************/
 
+type OrderType string
+
+const (
+	OrderTypeMarket            OrderType = "MKT"
+	OrderTypeLimit             OrderType = "LMT"
+	OrderTypeStop              OrderType = "STP"
+	OrderTypeStopLimit         OrderType = "STOP_LIMIT"
+	OrderTypeMarketIfTouched   OrderType = "MIT"
+	OrderTypeLimitIfTouched    OrderType = "LIT"
+	OrderTypeTrailingStop      OrderType = "TRAIL"
+	OrderTypeTrailingStopLimit OrderType = "TRAILLMT"
+	OrderTypeMarketOnClose     OrderType = "MOC"
+	OrderTypeLimitOnClose      OrderType = "LOC"
+	OrderTypeMidprice          OrderType = "MIDPRICE"
+)
+
+type TimeInForce string
+
+const (
+	TimeInForceDay               TimeInForce = "DAY"
+	TimeInForceGoodTillCanceled  TimeInForce = "GTC"
+	TimeInForceImmediateOrCancel TimeInForce = "IOC"
+	TimeInForceOpening           TimeInForce = "OPG"
+	TimeInForceOvernight         TimeInForce = "OVT"
+	TimeInForceOvernightDay      TimeInForce = "OND"
+)
+
+const (
+	OrderSideBuy  = "BUY"
+	OrderSideSell = "SELL"
+)
+
+const (
+	TrailingTypeAmount  = "amt"
+	TrailingTypePercent = "%"
+)
+
+// for STP and MIT orders Price is the trigger price. STOP_LIMIT and LIT orders take the limit in
+// Price and the trigger in AuxPrice.
 type Order struct {
-	AccountId   string  `json:"acctId"`
-	ConID       int32   `json:"conid"`
-	OrderType   string  `json:"orderType"`
-	Side        string  `json:"side"`
-	TimeInForce string  `json:"tif"`
-	Quantity    float64 `json:"quantity"`
+	AccountId            string            `json:"acctId"`
+	ConID                int32             `json:"conid,omitempty"`
+	ConIDEx              string            `json:"conidex,omitempty"`
+	SecType              string            `json:"secType,omitempty"`
+	ClientOrderID        string            `json:"cOID,omitempty"`
+	ParentID             string            `json:"parentId,omitempty"`
+	OrderType            OrderType         `json:"orderType"`
+	ListingExchange      string            `json:"listingExchange,omitempty"`
+	IsSingleGroup        bool              `json:"isSingleGroup,omitempty"`
+	OutsideRTH           bool              `json:"outsideRTH,omitempty"`
+	Price                float64           `json:"price,omitempty"`
+	AuxPrice             float64           `json:"auxPrice,omitempty"`
+	Side                 string            `json:"side"`
+	Ticker               string            `json:"ticker,omitempty"`
+	TimeInForce          TimeInForce       `json:"tif"`
+	TrailingAmount       float64           `json:"trailingAmt,omitempty"`
+	TrailingType         string            `json:"trailingType,omitempty"`
+	Referrer             string            `json:"referrer,omitempty"`
+	Quantity             float64           `json:"quantity,omitempty"`
+	CashQuantity         float64           `json:"cashQty,omitempty"`
+	UseAdaptive          bool              `json:"useAdaptive,omitempty"`
+	IsCurrencyConversion bool              `json:"isCcyConv,omitempty"`
+	AllocationMethod     string            `json:"allocationMethod,omitempty"`
+	Strategy             string            `json:"strategy,omitempty"`
+	StrategyParameters   map[string]string `json:"strategyParameters,omitempty"`
+}
+
+// checks the order for combinations ibkr would reject so they fail before any request is sent.
+func (o *Order) Validate() error {
+	if o.ConID == 0 && o.ConIDEx == "" {
+		return fmt.Errorf("%w: conid is required", ErrInvalidOrder)
+	}
+
+	if o.Side != OrderSideBuy && o.Side != OrderSideSell {
+		return fmt.Errorf("%w: invalid side %q", ErrInvalidOrder, o.Side)
+	}
+
+	if o.Quantity < 0 || o.CashQuantity < 0 {
+		return fmt.Errorf("%w: quantity must not be negative", ErrInvalidOrder)
+	}
+
+	if (o.Quantity == 0) == (o.CashQuantity == 0) {
+		return fmt.Errorf("%w: exactly one of quantity or cash quantity is required", ErrInvalidOrder)
+	}
+
+	if o.Price < 0 || o.AuxPrice < 0 {
+		return fmt.Errorf("%w: prices must not be negative", ErrInvalidOrder)
+	}
+
+	switch o.TimeInForce {
+	case TimeInForceDay, TimeInForceGoodTillCanceled, TimeInForceImmediateOrCancel,
+		TimeInForceOpening, TimeInForceOvernight, TimeInForceOvernightDay:
+	default:
+		return fmt.Errorf("%w: invalid time in force %q", ErrInvalidOrder, o.TimeInForce)
+	}
+
+	trailing := o.OrderType == OrderTypeTrailingStop || o.OrderType == OrderTypeTrailingStopLimit
+	if !trailing && (o.TrailingAmount != 0 || o.TrailingType != "") {
+		return fmt.Errorf("%w: trailing amount is only valid for trailing orders", ErrInvalidOrder)
+	}
+
+	switch o.OrderType {
+	case OrderTypeMarket, OrderTypeMarketOnClose:
+		if o.Price != 0 || o.AuxPrice != 0 {
+			return fmt.Errorf("%w: %s orders do not take a price", ErrInvalidOrder, o.OrderType)
+		}
+	case OrderTypeLimit, OrderTypeLimitOnClose, OrderTypeStop, OrderTypeMarketIfTouched:
+		if o.Price == 0 {
+			return fmt.Errorf("%w: %s orders require a price", ErrInvalidOrder, o.OrderType)
+		}
+	case OrderTypeStopLimit, OrderTypeLimitIfTouched:
+		if o.Price == 0 || o.AuxPrice == 0 {
+			return fmt.Errorf("%w: %s orders require a limit price and an aux price", ErrInvalidOrder, o.OrderType)
+		}
+	case OrderTypeTrailingStop, OrderTypeTrailingStopLimit:
+		if o.TrailingAmount <= 0 {
+			return fmt.Errorf("%w: %s orders require a trailing amount", ErrInvalidOrder, o.OrderType)
+		}
+		if o.TrailingType != TrailingTypeAmount && o.TrailingType != TrailingTypePercent {
+			return fmt.Errorf("%w: invalid trailing type %q", ErrInvalidOrder, o.TrailingType)
+		}
+		if o.OrderType == OrderTypeTrailingStopLimit && (o.Price == 0 || o.AuxPrice == 0) {
+			return fmt.Errorf("%w: %s orders require a stop price and a limit offset", ErrInvalidOrder, o.OrderType)
+		}
+	case OrderTypeMidprice:
+	default:
+		return fmt.Errorf("%w: invalid order type %q", ErrInvalidOrder, o.OrderType)
+	}
+
+	if o.OrderType == OrderTypeMarketOnClose || o.OrderType == OrderTypeLimitOnClose {
+		if o.TimeInForce != TimeInForceDay {
+			return fmt.Errorf("%w: %s orders must be DAY orders", ErrInvalidOrder, o.OrderType)
+		}
+	}
+
+	if o.TimeInForce == TimeInForceOpening && o.OrderType != OrderTypeMarket && o.OrderType != OrderTypeLimit {
+		return fmt.Errorf("%w: OPG is only valid for MKT and LMT orders", ErrInvalidOrder)
+	}
+
+	if o.OutsideRTH {
+		switch o.OrderType {
+		case OrderTypeMarket, OrderTypeMarketOnClose, OrderTypeLimitOnClose:
+			return fmt.Errorf("%w: %s orders cannot fill outside regular trading hours", ErrInvalidOrder, o.OrderType)
+		}
+	}
+
+	return nil
 }
 
 type PlaceOrderRequest struct {
@@ -23,68 +173,706 @@ type PlaceOrderRequest struct {
 }
 
 type PlaceOrderResponsePlain struct {
-	OrderID     string `json:"order_id" validation:"required"`
-	OrderStatus string `json:"order_status" validation:"required"`
+	OrderID     string `json:"order_id" validate:"required"`
+	OrderStatus string `json:"order_status" validate:"required"`
 }
 
 type PlaceOrderResponseMessage struct {
-	ID           string   `json:"id" validation:"required"`
-	Message      string   `json:"message" validation:"required"`
-	IsSuppressed bool     `json:"isSupressed" validation:"required"`
-	MessageIDs   []string `json:"messageIds" validation:"required"`
+	ID           string   `json:"id" validate:"required"`
+	Message      []string `json:"message" validate:"required"`
+	IsSuppressed bool     `json:"isSuppressed"`
+	MessageIDs   []string `json:"messageIds"`
 }
 
 type PlaceOrderRejectResponse struct {
-	Error string `json:"error" validation:"required"`
+	Error string `json:"error" validate:"required"`
 }
 
+// ID holds the order id once the order is submitted, or the reply id while Messages are
+// awaiting an answer through ReplyToOrderMessage.
 type PlaceOrderResponse struct {
-	ID         string
-	Status     string
-	Message    string
-	MessageIDs []string
+	ID            string
+	Status        string
+	ClientOrderID string
+	Message       string
+	Messages      []string
+	MessageIDs    []string
+}
+
+func (r *PlaceOrderResponse) NeedsReply() bool {
+	return r.Status == "" && (len(r.Messages) > 0 || len(r.MessageIDs) > 0)
 }
 
 func (c *IbkrWebClient) PlaceOrder(accountId string, order Order) (*PlaceOrderResponse, error) {
+	return c.PlaceOrderCtx(context.Background(), accountId, order)
+}
+
+// orders without a client order id are given a unique one, returned as ClientOrderID.
+func (c *IbkrWebClient) PlaceOrderCtx(ctx context.Context, accountId string, order Order) (*PlaceOrderResponse, error) {
+	err := order.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if order.ClientOrderID == "" {
+		order.ClientOrderID, err = newClientOrderID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	requestBody := PlaceOrderRequest{Orders: []Order{order}}
 
-	response, err := c.Post(fmt.Sprintf("/iserver/account/%s/orders", accountId), nil, requestBody)
+	response, err := c.PostCtx(ctx, fmt.Sprintf("/iserver/account/%s/orders", accountId), nil, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	orderResponse, err := c.submitSingleOrder(ctx, response)
+	if orderResponse != nil {
+		orderResponse.ClientOrderID = order.ClientOrderID
+	}
+
+	return orderResponse, err
+}
+
+/******************************************************************************
+* idempotent place order
+******************************************************************************/
+
+var IdempotentOrderAttempts = 3
+
+func (c *IbkrWebClient) PlaceOrderIdempotent(accountId string, order Order) (*PlaceOrderResponse, error) {
+	return c.PlaceOrderIdempotentCtx(context.Background(), accountId, order)
+}
+
+// places the order under a fixed client order id. when the outcome of a submission is unknown, e.g.
+// the request timed out after it was sent, live orders are searched for the client order id before
+// submitting again. ibkr also rejects a second order with the same client order id.
+func (c *IbkrWebClient) PlaceOrderIdempotentCtx(ctx context.Context, accountId string, order Order) (*PlaceOrderResponse, error) {
+	if order.ClientOrderID == "" {
+		clientOrderId, err := newClientOrderID()
+		if err != nil {
+			return nil, err
+		}
+		order.ClientOrderID = clientOrderId
+	}
+
+	var err error
+	for attempt := 1; attempt <= IdempotentOrderAttempts; attempt++ {
+		var orderResponse *PlaceOrderResponse
+		orderResponse, err = c.PlaceOrderCtx(ctx, accountId, order)
+		if err == nil || !isAmbiguousOrderError(err) || ctx.Err() != nil {
+			return orderResponse, err
+		}
+
+		if c.VerboseLogging {
+			log.Printf("---- ibkr order %s outcome unknown, checking live orders: %v", order.ClientOrderID, err)
+		}
+
+		existing, lookupErr := c.findLiveOrder(ctx, order.ClientOrderID)
+		if lookupErr != nil {
+			return nil, fmt.Errorf(
+				"order %s may have been placed, live order lookup failed: %w",
+				order.ClientOrderID,
+				errors.Join(err, lookupErr),
+			)
+		}
+
+		if existing != nil {
+			return &PlaceOrderResponse{
+				ID:            strconv.Itoa(int(existing.OrderID)),
+				Status:        existing.Status,
+				ClientOrderID: order.ClientOrderID,
+			}, nil
+		}
+	}
+
+	return nil, err
+}
+
+// ambiguous errors are those where the order may have reached ibkr: transport failures, timeouts
+// and server errors. anything else, including responses ibkr answered that could not be parsed, is
+// definite.
+func isAmbiguousOrderError(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// the connection dropped while reading the response
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// the wait between the live order lookups made before an order is resubmitted.
+var LiveOrderLookupDelay = time.Second
+
+// ibkr often answers the first orders request of a session with an empty list, so the live orders
+// are fetched twice before the order is considered missing.
+func (c *IbkrWebClient) findLiveOrder(ctx context.Context, clientOrderId string) (*OrderStatus, error) {
+	for lookup := 1; lookup <= 2; lookup++ {
+		if lookup > 1 {
+			timer := time.NewTimer(LiveOrderLookupDelay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		liveOrders, err := c.GetLiveOrdersCtx(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, order := range liveOrders.Orders {
+			if order.OrderRef == clientOrderId {
+				return &order, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func (c *IbkrWebClient) submitSingleOrder(ctx context.Context, response *clientResponse) (*PlaceOrderResponse, error) {
+	orderResponses, err := c.parseOrderResponses(response)
 	if err != nil {
 		return nil, err
 	}
 
+	orderResponses, err = c.answerOrderMessages(ctx, orderResponses, 1)
+	if orderResponses == nil {
+		return nil, err
+	}
+
+	return &orderResponses[0], err
+}
+
+// the order endpoints answer with either submitted orders, a question that must be replied to or
+// an error, so each shape is tried in turn.
+func (c *IbkrWebClient) parseOrderResponses(response *clientResponse) ([]PlaceOrderResponse, error) {
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("place order bad statusCode: %v", response.statusCode)
+		apiErr := newResponseError(response)
+		if response.statusCode == http.StatusBadRequest && apiErr.IbkrError != "" {
+			return nil, newOrderRejectedError(response, apiErr.IbkrError)
+		}
+		return nil, apiErr
 	}
 
 	var plainResponse []PlaceOrderResponsePlain
-	err = c.ParseJsonResponse(response, &plainResponse)
-	if err == nil {
-		return &PlaceOrderResponse{
-			ID:     plainResponse[0].OrderID,
-			Status: plainResponse[0].OrderStatus,
-		}, nil
+	err := c.ParseJsonResponse(response, &plainResponse)
+	if err == nil && len(plainResponse) > 0 {
+		orderResponses := make([]PlaceOrderResponse, len(plainResponse))
+		for i, plain := range plainResponse {
+			orderResponses[i] = PlaceOrderResponse{
+				ID:     plain.OrderID,
+				Status: plain.OrderStatus,
+			}
+		}
+		return orderResponses, nil
 	}
 
 	var messageResponse []PlaceOrderResponseMessage
 	err = c.ParseJsonResponse(response, &messageResponse)
-	if err == nil {
-		return &PlaceOrderResponse{
-			ID:         messageResponse[0].ID,
-			Message:    messageResponse[0].Message,
-			MessageIDs: messageResponse[0].MessageIDs,
-		}, nil
+	if err == nil && len(messageResponse) > 0 {
+		orderResponses := make([]PlaceOrderResponse, len(messageResponse))
+		for i, message := range messageResponse {
+			orderResponses[i] = PlaceOrderResponse{
+				ID:         message.ID,
+				Message:    strings.Join(message.Message, "\n"),
+				Messages:   message.Message,
+				MessageIDs: message.MessageIDs,
+			}
+		}
+		return orderResponses, nil
 	}
 
 	var rejectResponse PlaceOrderRejectResponse
 	err = c.ParseJsonResponse(response, &rejectResponse)
 	if err == nil {
-		return nil, fmt.Errorf("place order rejected: %s", rejectResponse.Error)
+		return nil, newOrderRejectedError(response, rejectResponse.Error)
 	}
 
 	return nil, fmt.Errorf("could not parse any possible response for place order")
 }
 
+/******************************************************************************
+* order replies
+******************************************************************************/
+
+var DefaultMaxOrderReplyRounds = 5
+
+// decides whether a precautionary order message is confirmed. message ids are the codes
+// listed in the order message constants, e.g. o163.
+type OrderReplyPolicy func(messageId MessageID, message string) bool
+
+// confirms only the listed message ids and declines everything else.
+func AcceptMessageIDs(messageIds ...MessageID) OrderReplyPolicy {
+	accepted := map[MessageID]bool{}
+	for _, id := range messageIds {
+		accepted[id] = true
+	}
+
+	return func(messageId MessageID, message string) bool {
+		return accepted[messageId]
+	}
+}
+
+type OrderReplyRequest struct {
+	Confirmed bool `json:"confirmed"`
+}
+
+func (c *IbkrWebClient) ReplyToOrderMessage(replyId string, confirmed bool) (*PlaceOrderResponse, error) {
+	return c.ReplyToOrderMessageCtx(context.Background(), replyId, confirmed)
+}
+
+func (c *IbkrWebClient) ReplyToOrderMessageCtx(ctx context.Context, replyId string, confirmed bool) (*PlaceOrderResponse, error) {
+	orderResponses, err := c.replyToOrderMessage(ctx, replyId, confirmed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orderResponses[0], nil
+}
+
+func (c *IbkrWebClient) replyToOrderMessage(ctx context.Context, replyId string, confirmed bool) ([]PlaceOrderResponse, error) {
+	requestBody := OrderReplyRequest{Confirmed: confirmed}
+
+	response, err := c.PostCtx(ctx, fmt.Sprintf("/iserver/reply/%s", replyId), nil, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parseOrderResponses(response)
+}
+
+// answers order messages with the client's OrderReplyPolicy until every order is submitted.
+// without a policy the pending responses are returned for the caller to reply to. if an order
+// still needs a reply after MaxOrderReplyRounds per order the responses are returned along with
+// the error.
+func (c *IbkrWebClient) answerOrderMessages(
+	ctx context.Context,
+	orderResponses []PlaceOrderResponse,
+	orderCount int,
+) ([]PlaceOrderResponse, error) {
+	if c.OrderReplyPolicy == nil {
+		return orderResponses, nil
+	}
+
+	for round := 0; ; round++ {
+		pending := slices.IndexFunc(orderResponses, func(r PlaceOrderResponse) bool { return r.NeedsReply() })
+		if pending < 0 {
+			return orderResponses, nil
+		}
+
+		orderResponse := orderResponses[pending]
+		if round >= c.MaxOrderReplyRounds*orderCount {
+			return orderResponses, fmt.Errorf(
+				"%w: reply %s still pending after %d rounds",
+				ErrOrderReplyRoundsExceeded,
+				orderResponse.ID,
+				round,
+			)
+		}
+
+		confirmed, declined := true, MessageID("")
+		for i := 0; i < max(len(orderResponse.MessageIDs), len(orderResponse.Messages)); i++ {
+			messageId, message := MessageID(""), ""
+			if i < len(orderResponse.MessageIDs) {
+				messageId = MessageID(orderResponse.MessageIDs[i])
+			}
+			if i < len(orderResponse.Messages) {
+				message = orderResponse.Messages[i]
+			}
+
+			if !c.OrderReplyPolicy(messageId, message) {
+				confirmed, declined = false, messageId
+				break
+			}
+		}
+
+		replyResponses, err := c.replyToOrderMessage(ctx, orderResponse.ID, confirmed)
+		if err != nil {
+			return nil, err
+		}
+
+		if !confirmed {
+			return nil, fmt.Errorf("%w: message %s declined by reply policy: %s", ErrOrderRejected, declined, orderResponse.Message)
+		}
+
+		// the reply answers for the pending entry, earlier entries are already submitted
+		orderResponses = slices.Concat(orderResponses[:pending], replyResponses, orderResponses[pending+1:])
+	}
+}
+
+/******************************************************************************
+* order groups
+******************************************************************************/
+
+// expands into the orders submitted together by PlaceOrderGroup.
+type OrderGroup interface {
+	Orders() ([]Order, error)
+}
+
+// an entry order with optional profit target and stop loss children. the children default to the
+// entry's account, contract, quantity and time in force on the opposite side, and ibkr cancels the
+// remaining child once one of them fills.
+type Bracket struct {
+	Entry      Order
+	TakeProfit *Order
+	StopLoss   *Order
+}
+
+func (b Bracket) Orders() ([]Order, error) {
+	if b.TakeProfit == nil && b.StopLoss == nil {
+		return nil, fmt.Errorf("%w: bracket requires a take profit or stop loss", ErrInvalidOrder)
+	}
+
+	entry := b.Entry
+	if entry.ClientOrderID == "" {
+		clientOrderId, err := newClientOrderID()
+		if err != nil {
+			return nil, err
+		}
+		entry.ClientOrderID = clientOrderId
+	}
+
+	orders := []Order{entry}
+
+	for _, child := range []struct {
+		order  *Order
+		suffix string
+	}{
+		{b.TakeProfit, "tp"},
+		{b.StopLoss, "sl"},
+	} {
+		if child.order == nil {
+			continue
+		}
+
+		order := *child.order
+		order.ParentID = entry.ClientOrderID
+		if order.ClientOrderID == "" {
+			order.ClientOrderID = fmt.Sprintf("%s-%s", entry.ClientOrderID, child.suffix)
+		}
+		if order.AccountId == "" {
+			order.AccountId = entry.AccountId
+		}
+		if order.ConID == 0 && order.ConIDEx == "" {
+			order.ConID, order.ConIDEx = entry.ConID, entry.ConIDEx
+		}
+		if order.Side == "" {
+			order.Side = oppositeSide(entry.Side)
+		}
+		if order.Quantity == 0 && order.CashQuantity == 0 {
+			order.Quantity = entry.Quantity
+		}
+		if order.TimeInForce == "" {
+			order.TimeInForce = entry.TimeInForce
+		}
+
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+// one-cancels-all group, the first leg to fill cancels the others. ibkr groups the legs submitted
+// together in one request and has no oca group name, so legs without a client order id are given
+// ids of the form <generated prefix>-<n>.
+type OCAGroup struct {
+	Legs []Order
+}
+
+func (g OCAGroup) Orders() ([]Order, error) {
+	if len(g.Legs) < 2 {
+		return nil, fmt.Errorf("%w: oca group requires at least two legs", ErrInvalidOrder)
+	}
+
+	prefix, err := newClientOrderID()
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]Order, len(g.Legs))
+	for i, leg := range g.Legs {
+		leg.IsSingleGroup = true
+		if leg.ClientOrderID == "" {
+			leg.ClientOrderID = fmt.Sprintf("%s-%d", prefix, i+1)
+		}
+		orders[i] = leg
+	}
+
+	return orders, nil
+}
+
+func oppositeSide(side string) string {
+	if side == OrderSideBuy {
+		return OrderSideSell
+	}
+	return OrderSideBuy
+}
+
+func newClientOrderID() (string, error) {
+	nonce, err := generateNonce(64)
+	if err != nil {
+		return "", err
+	}
+
+	return nonce.Text(36), nil
+}
+
+func (c *IbkrWebClient) PlaceOrderGroup(accountId string, group OrderGroup) ([]PlaceOrderResponse, error) {
+	return c.PlaceOrderGroupCtx(context.Background(), accountId, group)
+}
+
+// submits all orders of the group in a single request. each leg's reply messages are answered with
+// the OrderReplyPolicy, and the submitted orders are returned in the order ibkr reports them.
+func (c *IbkrWebClient) PlaceOrderGroupCtx(ctx context.Context, accountId string, group OrderGroup) ([]PlaceOrderResponse, error) {
+	orders, err := group.Orders()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range orders {
+		err = orders[i].Validate()
+		if err != nil {
+			return nil, fmt.Errorf("order group leg %d: %w", i+1, err)
+		}
+
+		if orders[i].ClientOrderID == "" {
+			orders[i].ClientOrderID, err = newClientOrderID()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	requestBody := PlaceOrderRequest{Orders: orders}
+
+	response, err := c.PostCtx(ctx, fmt.Sprintf("/iserver/account/%s/orders", accountId), nil, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	orderResponses, err := c.parseOrderResponses(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.answerOrderMessages(ctx, orderResponses, len(orders))
+}
+
+/******************************************************************************
+* modify order
+******************************************************************************/
+
+func (c *IbkrWebClient) ModifyOrder(accountId string, orderId string, changes Order) (*PlaceOrderResponse, error) {
+	return c.ModifyOrderCtx(context.Background(), accountId, orderId, changes)
+}
+
+// ibkr replaces the working order with the given one, so changes must hold the complete order and
+// not only the modified fields.
+func (c *IbkrWebClient) ModifyOrderCtx(ctx context.Context, accountId string, orderId string, changes Order) (*PlaceOrderResponse, error) {
+	err := changes.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.PostCtx(ctx, fmt.Sprintf("/iserver/account/%s/order/%s", accountId, orderId), nil, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.submitSingleOrder(ctx, response)
+}
+
+/******************************************************************************
+* preview order
+******************************************************************************/
+
+type previewOrderAmount struct {
+	Amount     string `json:"amount"`
+	Commission string `json:"commission"`
+	Total      string `json:"total"`
+}
+
+type previewOrderValues struct {
+	Current string `json:"current"`
+	Change  string `json:"change"`
+	After   string `json:"after"`
+}
+
+type previewOrderResponse struct {
+	Amount      previewOrderAmount `json:"amount"`
+	Equity      previewOrderValues `json:"equity"`
+	Initial     previewOrderValues `json:"initial"`
+	Maintenance previewOrderValues `json:"maintenance"`
+	Position    previewOrderValues `json:"position"`
+	Warn        string             `json:"warn"`
+	Error       string             `json:"error"`
+}
+
+type PreviewChange struct {
+	Current float64
+	Change  float64
+	After   float64
+}
+
+// commission is reported as a range when ibkr cannot price it exactly, otherwise min and max
+// are equal.
+type OrderPreview struct {
+	Amount            float64
+	Total             float64
+	Currency          string
+	CommissionMin     float64
+	CommissionMax     float64
+	EquityWithLoan    PreviewChange
+	InitialMargin     PreviewChange
+	MaintenanceMargin PreviewChange
+	Position          PreviewChange
+	Warning           string
+}
+
+func (c *IbkrWebClient) PreviewOrder(accountId string, order Order) (*OrderPreview, error) {
+	return c.PreviewOrderCtx(context.Background(), accountId, order)
+}
+
+func (c *IbkrWebClient) PreviewOrderCtx(ctx context.Context, accountId string, order Order) (*OrderPreview, error) {
+	err := order.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	requestBody := PlaceOrderRequest{Orders: []Order{order}}
+
+	response, err := c.PostCtx(ctx, fmt.Sprintf("/iserver/account/%s/orders/whatif", accountId), nil, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.statusCode != http.StatusOK {
+		apiErr := newResponseError(response)
+		if response.statusCode == http.StatusBadRequest && apiErr.IbkrError != "" {
+			return nil, newOrderRejectedError(response, apiErr.IbkrError)
+		}
+		return nil, apiErr
+	}
+
+	var responseStruct previewOrderResponse
+	err = c.ParseJsonResponse(response, &responseStruct)
+	if err != nil {
+		return nil, err
+	}
+
+	if responseStruct.Error != "" {
+		return nil, newOrderRejectedError(response, responseStruct.Error)
+	}
+
+	return parseOrderPreview(&responseStruct)
+}
+
+func parseOrderPreview(raw *previewOrderResponse) (*OrderPreview, error) {
+	preview := OrderPreview{Warning: raw.Warn}
+
+	amounts, currency, err := parsePreviewAmounts(raw.Amount.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing preview amount: %w", err)
+	}
+	if len(amounts) > 0 {
+		preview.Amount = amounts[0]
+	}
+	preview.Currency = currency
+
+	commissions, _, err := parsePreviewAmounts(raw.Amount.Commission)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing preview commission: %w", err)
+	}
+	if len(commissions) > 0 {
+		preview.CommissionMin = slices.Min(commissions)
+		preview.CommissionMax = slices.Max(commissions)
+	}
+
+	totals, _, err := parsePreviewAmounts(raw.Amount.Total)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing preview total: %w", err)
+	}
+	if len(totals) > 0 {
+		preview.Total = totals[0]
+	}
+
+	for _, change := range []struct {
+		name string
+		raw  previewOrderValues
+		dest *PreviewChange
+	}{
+		{"equity", raw.Equity, &preview.EquityWithLoan},
+		{"initial margin", raw.Initial, &preview.InitialMargin},
+		{"maintenance margin", raw.Maintenance, &preview.MaintenanceMargin},
+		{"position", raw.Position, &preview.Position},
+	} {
+		for _, value := range []struct {
+			raw  string
+			dest *float64
+		}{
+			{change.raw.Current, &change.dest.Current},
+			{change.raw.Change, &change.dest.Change},
+			{change.raw.After, &change.dest.After},
+		} {
+			amounts, _, err := parsePreviewAmounts(value.raw)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing preview %s: %w", change.name, err)
+			}
+			if len(amounts) > 0 {
+				*value.dest = amounts[0]
+			}
+		}
+	}
+
+	return &preview, nil
+}
+
+// parses values such as "23,000 USD (100 Shares)" or "1.00 - 2.50 USD" into their numbers and
+// currency. anything in parentheses is descriptive and skipped.
+func parsePreviewAmounts(value string) ([]float64, string, error) {
+	value, _, _ = strings.Cut(value, "(")
+
+	var amounts []float64
+	currency := ""
+	for _, field := range strings.Fields(value) {
+		if field == "-" {
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(field, ",", ""), 64)
+		if err == nil {
+			amounts = append(amounts, amount)
+			continue
+		}
+
+		if len(field) == 3 && strings.Trim(field, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == "" {
+			currency = field
+			continue
+		}
+
+		return nil, "", fmt.Errorf("unexpected value: %s", value)
+	}
+
+	return amounts, currency, nil
+}
+
 /******************************************************************************
 * cancel order
 ******************************************************************************/
@@ -101,13 +889,17 @@ type CancelOrderErrorResponse struct {
 }
 
 func (c *IbkrWebClient) CancelOrder(accountId string, orderId string) (*CancelOrderResponse, error) {
-	response, err := c.Delete(fmt.Sprintf("/iserver/account/%s/order/%s", accountId, orderId), nil)
+	return c.CancelOrderCtx(context.Background(), accountId, orderId)
+}
+
+func (c *IbkrWebClient) CancelOrderCtx(ctx context.Context, accountId string, orderId string) (*CancelOrderResponse, error) {
+	response, err := c.DeleteCtx(ctx, fmt.Sprintf("/iserver/account/%s/order/%s", accountId, orderId), nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("cancel order bad statusCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
 	var responseStruct CancelOrderResponse
@@ -138,16 +930,21 @@ type OrderStatus struct {
 	OrderType         string  `json:"orderType" validation:"required"`
 	Side              string  `json:"side" validation:"required"`
 	TimeInForce       string  `json:"timeInForce" validation:"required"`
+	OrderRef          string  `json:"order_ref"`
 }
 
 func (c *IbkrWebClient) GetLiveOrders() (*LiveOrdersResponse, error) {
-	response, err := c.Get("/iserver/account/orders", nil)
+	return c.GetLiveOrdersCtx(context.Background())
+}
+
+func (c *IbkrWebClient) GetLiveOrdersCtx(ctx context.Context) (*LiveOrdersResponse, error) {
+	response, err := c.GetCtx(ctx, "/iserver/account/orders", nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("get live orders bad statusCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
 	var responseStruct LiveOrdersResponse
@@ -160,14 +957,228 @@ func (c *IbkrWebClient) GetLiveOrders() (*LiveOrdersResponse, error) {
 }
 
 /******************************************************************************
-* suppress messages
+* order status
 ******************************************************************************/
 
-const (
-	MessageIdImmediateFill          = "o403"
-	MessageIdMarketOrderRisk        = "o10151"
-	MessageIdOrderWithoutMarketData = "o354"
-)
+const orderTimeLayout = "060102150405"
+
+type orderStatusResponse struct {
+	OrderID           int64     `json:"order_id" validate:"required"`
+	ConID             int32     `json:"conid"`
+	Symbol            string    `json:"symbol"`
+	Side              string    `json:"side"`
+	Account           string    `json:"account"`
+	OrderType         string    `json:"order_type"`
+	OrderStatus       string    `json:"order_status"`
+	StatusDescription string    `json:"order_status_description"`
+	TimeInForce       string    `json:"tif"`
+	TotalSize         flexFloat `json:"total_size"`
+	RemainingSize     flexFloat `json:"size"`
+	CumulativeFill    flexFloat `json:"cum_fill"`
+	AveragePrice      flexFloat `json:"average_price"`
+	LimitPrice        flexFloat `json:"limit_price"`
+	StopPrice         flexFloat `json:"stop_price"`
+	Currency          string    `json:"currency"`
+	ListingExchange   string    `json:"listing_exchange"`
+	SecType           string    `json:"sec_type"`
+	Description       string    `json:"order_description_with_contract"`
+	NotEditable       bool      `json:"order_not_editable"`
+	CannotCancel      bool      `json:"cannot_cancel_order"`
+	OrderTime         string    `json:"order_time"`
+}
+
+type OrderDetail struct {
+	OrderID           int64
+	ConID             int32
+	Symbol            string
+	Side              string
+	Account           string
+	OrderType         string
+	Status            string
+	StatusDescription string
+	TimeInForce       string
+	TotalSize         float64
+	RemainingSize     float64
+	FilledSize        float64
+	AveragePrice      float64
+	LimitPrice        float64
+	StopPrice         float64
+	Currency          string
+	ListingExchange   string
+	SecType           string
+	Description       string
+	Editable          bool
+	Cancellable       bool
+	OrderTime         time.Time
+}
+
+func (c *IbkrWebClient) GetOrderStatus(orderId string) (*OrderDetail, error) {
+	return c.GetOrderStatusCtx(context.Background(), orderId)
+}
+
+func (c *IbkrWebClient) GetOrderStatusCtx(ctx context.Context, orderId string) (*OrderDetail, error) {
+	response, err := c.GetCtx(ctx, fmt.Sprintf("/iserver/account/order/status/%s", orderId), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.statusCode != http.StatusOK {
+		return nil, newResponseError(response)
+	}
+
+	var responseStruct orderStatusResponse
+	err = c.ParseJsonResponse(response, &responseStruct)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := OrderDetail{
+		OrderID:           responseStruct.OrderID,
+		ConID:             responseStruct.ConID,
+		Symbol:            responseStruct.Symbol,
+		Side:              responseStruct.Side,
+		Account:           responseStruct.Account,
+		OrderType:         responseStruct.OrderType,
+		Status:            responseStruct.OrderStatus,
+		StatusDescription: responseStruct.StatusDescription,
+		TimeInForce:       responseStruct.TimeInForce,
+		TotalSize:         float64(responseStruct.TotalSize),
+		RemainingSize:     float64(responseStruct.RemainingSize),
+		FilledSize:        float64(responseStruct.CumulativeFill),
+		AveragePrice:      float64(responseStruct.AveragePrice),
+		LimitPrice:        float64(responseStruct.LimitPrice),
+		StopPrice:         float64(responseStruct.StopPrice),
+		Currency:          responseStruct.Currency,
+		ListingExchange:   responseStruct.ListingExchange,
+		SecType:           responseStruct.SecType,
+		Description:       responseStruct.Description,
+		Editable:          !responseStruct.NotEditable,
+		Cancellable:       !responseStruct.CannotCancel,
+	}
+
+	// order times are reported in utc as yyMMddHHmmss
+	if responseStruct.OrderTime != "" {
+		detail.OrderTime, err = time.Parse(orderTimeLayout, responseStruct.OrderTime)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing order time: %w", err)
+		}
+	}
+
+	return &detail, nil
+}
+
+/******************************************************************************
+* trades
+******************************************************************************/
+
+const MaxTradeDays = 7
+
+const tradeTimeLayout = "20060102-15:04:05"
+
+type tradeResponse struct {
+	ExecutionID     string    `json:"execution_id" validate:"required"`
+	OrderRef        string    `json:"order_ref"`
+	Account         string    `json:"account"`
+	ConID           int32     `json:"conid"`
+	Symbol          string    `json:"symbol"`
+	Side            string    `json:"side"`
+	Size            flexFloat `json:"size"`
+	Price           flexFloat `json:"price"`
+	Commission      flexFloat `json:"commission"`
+	NetAmount       flexFloat `json:"net_amount"`
+	Exchange        string    `json:"exchange"`
+	ListingExchange string    `json:"listing_exchange"`
+	SecType         string    `json:"sec_type"`
+	Description     string    `json:"order_description"`
+	TradeTime       string    `json:"trade_time"`
+	TradeTimeMillis int64     `json:"trade_time_r"`
+}
+
+type Execution struct {
+	ExecutionID     string
+	OrderRef        string
+	Account         string
+	ConID           int32
+	Symbol          string
+	Side            string
+	Size            float64
+	Price           float64
+	Commission      float64
+	NetAmount       float64
+	Exchange        string
+	ListingExchange string
+	SecType         string
+	Description     string
+	TradeTime       time.Time
+}
+
+func (c *IbkrWebClient) GetTrades(days int) ([]Execution, error) {
+	return c.GetTradesCtx(context.Background(), days)
+}
+
+// returns executions from the current day plus the given number of previous days, up to
+// MaxTradeDays.
+func (c *IbkrWebClient) GetTradesCtx(ctx context.Context, days int) ([]Execution, error) {
+	if days < 0 || days > MaxTradeDays {
+		return nil, fmt.Errorf("trade days must be between 0 and %d, found: %d", MaxTradeDays, days)
+	}
+
+	var queryParams map[string]string
+	if days > 0 {
+		queryParams = map[string]string{"days": strconv.Itoa(days)}
+	}
+
+	response, err := c.GetCtx(ctx, "/iserver/account/trades", queryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.statusCode != http.StatusOK {
+		return nil, newResponseError(response)
+	}
+
+	var responseStruct []tradeResponse
+	err = c.ParseJsonResponse(response, &responseStruct)
+	if err != nil {
+		return nil, err
+	}
+
+	executions := make([]Execution, len(responseStruct))
+	for i, trade := range responseStruct {
+		executions[i] = Execution{
+			ExecutionID:     trade.ExecutionID,
+			OrderRef:        trade.OrderRef,
+			Account:         trade.Account,
+			ConID:           trade.ConID,
+			Symbol:          trade.Symbol,
+			Side:            trade.Side,
+			Size:            float64(trade.Size),
+			Price:           float64(trade.Price),
+			Commission:      float64(trade.Commission),
+			NetAmount:       float64(trade.NetAmount),
+			Exchange:        trade.Exchange,
+			ListingExchange: trade.ListingExchange,
+			SecType:         trade.SecType,
+			Description:     trade.Description,
+		}
+
+		// prefer the epoch timestamp, the formatted trade time is utc without a zone
+		if trade.TradeTimeMillis != 0 {
+			executions[i].TradeTime = time.UnixMilli(trade.TradeTimeMillis).UTC()
+		} else if trade.TradeTime != "" {
+			executions[i].TradeTime, err = time.Parse(tradeTimeLayout, trade.TradeTime)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing trade time for execution %s: %w", trade.ExecutionID, err)
+			}
+		}
+	}
+
+	return executions, nil
+}
+
+/******************************************************************************
+* suppress messages
+******************************************************************************/
 
 var MessagesToSupress = []string{
 	MessageIdImmediateFill,
@@ -179,16 +1190,51 @@ type SupressMessagesRequest struct {
 	MessageIDs []string `json:"messageIds"`
 }
 
+// suppresses the default MessagesToSupress set.
 func (c *IbkrWebClient) SuppressMessages() error {
-	requestBody := SupressMessagesRequest{MessageIDs: MessagesToSupress}
+	return c.SuppressMessagesCtx(context.Background())
+}
+
+func (c *IbkrWebClient) SuppressMessagesCtx(ctx context.Context) error {
+	return c.SuppressMessageIDsCtx(ctx, MessagesToSupress)
+}
+
+// suppression applies to the whole brokerage session until it is reset or the session ends.
+func (c *IbkrWebClient) SuppressMessageIDs(messageIds []string) error {
+	return c.SuppressMessageIDsCtx(context.Background(), messageIds)
+}
+
+func (c *IbkrWebClient) SuppressMessageIDsCtx(ctx context.Context, messageIds []string) error {
+	if len(messageIds) == 0 {
+		return fmt.Errorf("no message ids to suppress")
+	}
+
+	requestBody := SupressMessagesRequest{MessageIDs: messageIds}
+
+	response, err := c.PostCtx(ctx, "/iserver/questions/suppress", nil, requestBody)
+	if err != nil {
+		return err
+	}
+
+	if response.statusCode != http.StatusOK {
+		return newResponseError(response)
+	}
+
+	return nil
+}
+
+func (c *IbkrWebClient) ResetSuppressedMessages() error {
+	return c.ResetSuppressedMessagesCtx(context.Background())
+}
 
-	response, err := c.Post("/iserver/questions/suppress", nil, requestBody)
+func (c *IbkrWebClient) ResetSuppressedMessagesCtx(ctx context.Context) error {
+	response, err := c.PostCtx(ctx, "/iserver/questions/suppress/reset", nil, nil)
 	if err != nil {
 		return err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return fmt.Errorf("suppress messages bad statusCode: %v", response.statusCode)
+		return newResponseError(response)
 	}
 
 	return nil
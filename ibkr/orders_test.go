@@ -2,14 +2,27 @@ package ibkr
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+var testMarketOrder = Order{
+	AccountId:   "1234",
+	ConID:       265598,
+	OrderType:   OrderTypeMarket,
+	Side:        OrderSideBuy,
+	TimeInForce: TimeInForceDay,
+	Quantity:    5,
+}
+
 var testPlaceOrderResponsePlain = `[
   {
     "order_id": "1234567890",
@@ -96,7 +109,7 @@ func TestIbkrWebClient_PlaceOrderPlain(t *testing.T) {
 	defer mockServer.Close()
 
 	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
-	rsp, err := client.PlaceOrder("1234", Order{})
+	rsp, err := client.PlaceOrder("1234", testMarketOrder)
 
 	assert.NotNil(t, rsp)
 	assert.NoError(t, err)
@@ -119,7 +132,7 @@ func TestIbkrWebClient_PlaceOrderMessage(t *testing.T) {
 	defer mockServer.Close()
 
 	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
-	rsp, err := client.PlaceOrder("1234", Order{})
+	rsp, err := client.PlaceOrder("1234", testMarketOrder)
 
 	assert.NotNil(t, rsp)
 	assert.NoError(t, err)
@@ -142,10 +155,11 @@ func TestIbkrWebClient_PlaceOrderReject(t *testing.T) {
 	defer mockServer.Close()
 
 	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
-	rsp, err := client.PlaceOrder("1234", Order{})
+	rsp, err := client.PlaceOrder("1234", testMarketOrder)
 
 	assert.Nil(t, rsp)
 	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrOrderRejected)
 }
 
 func TestIbkrWebClient_CancelOrder(t *testing.T) {
@@ -177,3 +191,679 @@ func TestIbkrWebClient_GetLiveOrders(t *testing.T) {
 	assert.NotNil(t, rsp)
 	assert.NoError(t, err)
 }
+
+func TestIbkrWebClient_ReplyToOrderMessage(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/api/iserver/reply/07a13a5a", r.URL.Path)
+
+		var reqBody OrderReplyRequest
+		err := json.NewDecoder(r.Body).Decode(&reqBody)
+		assert.NoError(t, err)
+		assert.True(t, reqBody.Confirmed)
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, testPlaceOrderResponsePlain)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	rsp, err := client.ReplyToOrderMessage("07a13a5a", true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1234567890", rsp.ID)
+	assert.Equal(t, "Submitted", rsp.Status)
+	assert.False(t, rsp.NeedsReply())
+}
+
+func TestIbkrWebClient_PlaceOrderMessageParsed(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, testPlaceOrderResponseMessage)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	rsp, err := client.PlaceOrder("1234", testMarketOrder)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "07a13a5a-4a48-44a5-bb25-5ab37b79186c", rsp.ID)
+	assert.Equal(t, []string{"o163"}, rsp.MessageIDs)
+	assert.Contains(t, rsp.Message, "Percentage constraint")
+	assert.True(t, rsp.NeedsReply())
+}
+
+func newTestOrderReplyServer(t *testing.T, confirmations *[]bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+
+		if r.URL.Path == "/v1/api/iserver/account/1234/orders" {
+			io.WriteString(w, testPlaceOrderResponseMessage)
+			return
+		}
+
+		var reqBody OrderReplyRequest
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		*confirmations = append(*confirmations, reqBody.Confirmed)
+
+		if len(*confirmations) == 1 {
+			io.WriteString(w, `[{"id": "second", "message": ["margin warning"], "messageIds": ["o10331"]}]`)
+			return
+		}
+		io.WriteString(w, testPlaceOrderResponsePlain)
+	}))
+}
+
+func TestIbkrWebClient_PlaceOrderAutoReply(t *testing.T) {
+	var confirmations []bool
+	mockServer := newTestOrderReplyServer(t, &confirmations)
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.OrderReplyPolicy = AcceptMessageIDs("o163", "o10331")
+
+	rsp, err := client.PlaceOrder("1234", testMarketOrder)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1234567890", rsp.ID)
+	assert.Equal(t, []bool{true, true}, confirmations)
+}
+
+func TestIbkrWebClient_PlaceOrderAutoReplyDeclined(t *testing.T) {
+	var confirmations []bool
+	mockServer := newTestOrderReplyServer(t, &confirmations)
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.OrderReplyPolicy = AcceptMessageIDs("o163")
+
+	rsp, err := client.PlaceOrder("1234", testMarketOrder)
+
+	assert.Nil(t, rsp)
+	assert.ErrorIs(t, err, ErrOrderRejected)
+	assert.Equal(t, []bool{true, false}, confirmations)
+}
+
+func TestIbkrWebClient_PlaceOrderAutoReplyRoundsExceeded(t *testing.T) {
+	var confirmations []bool
+	mockServer := newTestOrderReplyServer(t, &confirmations)
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.OrderReplyPolicy = AcceptMessageIDs("o163", "o10331")
+	client.MaxOrderReplyRounds = 1
+
+	rsp, err := client.PlaceOrder("1234", testMarketOrder)
+
+	assert.ErrorIs(t, err, ErrOrderReplyRoundsExceeded)
+	assert.Equal(t, "second", rsp.ID)
+	assert.True(t, rsp.NeedsReply())
+}
+
+func TestOrder_Validate(t *testing.T) {
+	withChanges := func(change func(o *Order)) Order {
+		order := testMarketOrder
+		change(&order)
+		return order
+	}
+
+	tests := []struct {
+		name  string
+		order Order
+		valid bool
+	}{
+		{"market", testMarketOrder, true},
+		{"cash quantity", withChanges(func(o *Order) { o.Quantity, o.CashQuantity = 0, 1000 }), true},
+		{"limit", withChanges(func(o *Order) { o.OrderType, o.Price, o.OutsideRTH = OrderTypeLimit, 150, true }), true},
+		{"stop limit", withChanges(func(o *Order) { o.OrderType, o.Price, o.AuxPrice = OrderTypeStopLimit, 149, 150 }), true},
+		{"trailing", withChanges(func(o *Order) {
+			o.OrderType, o.Price, o.TrailingAmount, o.TrailingType = OrderTypeTrailingStop, 145, 1.5, TrailingTypeAmount
+		}), true},
+		{"midprice", withChanges(func(o *Order) { o.OrderType = OrderTypeMidprice }), true},
+		{"missing conid", withChanges(func(o *Order) { o.ConID = 0 }), false},
+		{"bad side", withChanges(func(o *Order) { o.Side = "buy" }), false},
+		{"no quantity", withChanges(func(o *Order) { o.Quantity = 0 }), false},
+		{"both quantities", withChanges(func(o *Order) { o.CashQuantity = 1000 }), false},
+		{"bad tif", withChanges(func(o *Order) { o.TimeInForce = "GTD" }), false},
+		{"bad order type", withChanges(func(o *Order) { o.OrderType = "Market" }), false},
+		{"market with price", withChanges(func(o *Order) { o.Price = 150 }), false},
+		{"limit without price", withChanges(func(o *Order) { o.OrderType = OrderTypeLimit }), false},
+		{"stop limit without aux", withChanges(func(o *Order) { o.OrderType, o.Price = OrderTypeStopLimit, 149 }), false},
+		{"trailing without amount", withChanges(func(o *Order) { o.OrderType, o.Price = OrderTypeTrailingStop, 145 }), false},
+		{"trailing amount on limit", withChanges(func(o *Order) {
+			o.OrderType, o.Price, o.TrailingAmount = OrderTypeLimit, 150, 1
+		}), false},
+		{"market on close gtc", withChanges(func(o *Order) {
+			o.OrderType, o.TimeInForce = OrderTypeMarketOnClose, TimeInForceGoodTillCanceled
+		}), false},
+		{"opening stop", withChanges(func(o *Order) {
+			o.OrderType, o.Price, o.TimeInForce = OrderTypeStop, 140, TimeInForceOpening
+		}), false},
+		{"market outside rth", withChanges(func(o *Order) { o.OutsideRTH = true }), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.order.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidOrder)
+			}
+		})
+	}
+}
+
+func TestIbkrWebClient_PlaceOrderInvalid(t *testing.T) {
+	requests := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	rsp, err := client.PlaceOrder("1234", Order{})
+
+	assert.Nil(t, rsp)
+	assert.ErrorIs(t, err, ErrInvalidOrder)
+	assert.Equal(t, 0, requests)
+}
+
+func TestBracket_Orders(t *testing.T) {
+	entry := testMarketOrder
+	entry.ClientOrderID = "entry1"
+
+	bracket := Bracket{
+		Entry:      entry,
+		TakeProfit: &Order{OrderType: OrderTypeLimit, Price: 160},
+		StopLoss:   &Order{OrderType: OrderTypeStop, Price: 140, TimeInForce: TimeInForceGoodTillCanceled},
+	}
+
+	orders, err := bracket.Orders()
+	assert.NoError(t, err)
+	assert.Len(t, orders, 3)
+
+	assert.Equal(t, "entry1", orders[0].ClientOrderID)
+	assert.Empty(t, orders[0].ParentID)
+
+	for i, suffix := range []string{"tp", "sl"} {
+		child := orders[i+1]
+		assert.Equal(t, "entry1", child.ParentID)
+		assert.Equal(t, "entry1-"+suffix, child.ClientOrderID)
+		assert.Equal(t, OrderSideSell, child.Side)
+		assert.Equal(t, entry.Quantity, child.Quantity)
+		assert.Equal(t, entry.ConID, child.ConID)
+		assert.NoError(t, child.Validate())
+	}
+
+	assert.Equal(t, entry.TimeInForce, orders[1].TimeInForce)
+	assert.Equal(t, TimeInForceGoodTillCanceled, orders[2].TimeInForce)
+
+	_, err = Bracket{Entry: entry}.Orders()
+	assert.ErrorIs(t, err, ErrInvalidOrder)
+
+	orders, err = Bracket{Entry: testMarketOrder, StopLoss: bracket.StopLoss}.Orders()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, orders[0].ClientOrderID)
+	assert.Equal(t, orders[0].ClientOrderID, orders[1].ParentID)
+}
+
+func TestOCAGroup_Orders(t *testing.T) {
+	limit := testMarketOrder
+	limit.OrderType, limit.Price = OrderTypeLimit, 150
+
+	orders, err := OCAGroup{Legs: []Order{limit, testMarketOrder}}.Orders()
+	assert.NoError(t, err)
+	assert.Len(t, orders, 2)
+
+	prefix, _, found := strings.Cut(orders[0].ClientOrderID, "-")
+	assert.True(t, found)
+	assert.Equal(t, prefix+"-1", orders[0].ClientOrderID)
+	assert.Equal(t, prefix+"-2", orders[1].ClientOrderID)
+	assert.True(t, orders[0].IsSingleGroup)
+	assert.True(t, orders[1].IsSingleGroup)
+
+	_, err = OCAGroup{Legs: []Order{limit}}.Orders()
+	assert.ErrorIs(t, err, ErrInvalidOrder)
+}
+
+func TestIbkrWebClient_PlaceOrderGroup(t *testing.T) {
+	var replies []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+
+		if r.URL.Path == "/v1/api/iserver/account/1234/orders" {
+			var reqBody PlaceOrderRequest
+			err := json.NewDecoder(r.Body).Decode(&reqBody)
+			assert.NoError(t, err)
+			assert.Len(t, reqBody.Orders, 3)
+
+			io.WriteString(w, `[{"id": "reply1", "message": ["entry warning"], "messageIds": ["o163"]}]`)
+			return
+		}
+
+		replies = append(replies, r.URL.Path)
+		if len(replies) == 1 {
+			io.WriteString(w, `[{"id": "reply2", "message": ["child warning"], "messageIds": ["o163"]}]`)
+			return
+		}
+		io.WriteString(w, `[
+			{"order_id": "1", "order_status": "PreSubmitted"},
+			{"order_id": "2", "order_status": "PreSubmitted"},
+			{"order_id": "3", "order_status": "PreSubmitted"}
+		]`)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.OrderReplyPolicy = AcceptMessageIDs("o163")
+
+	bracket := Bracket{
+		Entry:      testMarketOrder,
+		TakeProfit: &Order{OrderType: OrderTypeLimit, Price: 160},
+		StopLoss:   &Order{OrderType: OrderTypeStop, Price: 140, TimeInForce: TimeInForceGoodTillCanceled},
+	}
+
+	rsp, err := client.PlaceOrderGroup("1234", bracket)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/v1/api/iserver/reply/reply1", "/v1/api/iserver/reply/reply2"}, replies)
+	assert.Len(t, rsp, 3)
+	for i, orderResponse := range rsp {
+		assert.Equal(t, fmt.Sprint(i+1), orderResponse.ID)
+		assert.False(t, orderResponse.NeedsReply())
+	}
+}
+
+func TestIbkrWebClient_ModifyOrder(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+
+		if r.URL.Path == "/v1/api/iserver/account/1234/order/1234567890" {
+			assert.Equal(t, "POST", r.Method)
+
+			var reqBody Order
+			err := json.NewDecoder(r.Body).Decode(&reqBody)
+			assert.NoError(t, err)
+			assert.Equal(t, 151.5, reqBody.Price)
+
+			io.WriteString(w, testPlaceOrderResponseMessage)
+			return
+		}
+
+		assert.Equal(t, "/v1/api/iserver/reply/07a13a5a-4a48-44a5-bb25-5ab37b79186c", r.URL.Path)
+		io.WriteString(w, testPlaceOrderResponsePlain)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.OrderReplyPolicy = AcceptMessageIDs("o163")
+
+	changes := testMarketOrder
+	changes.OrderType, changes.Price = OrderTypeLimit, 151.5
+
+	rsp, err := client.ModifyOrder("1234", "1234567890", changes)
+	assert.NoError(t, err)
+	assert.Equal(t, "1234567890", rsp.ID)
+	assert.Equal(t, "Submitted", rsp.Status)
+
+	_, err = client.ModifyOrder("1234", "1234567890", Order{})
+	assert.ErrorIs(t, err, ErrInvalidOrder)
+}
+
+var testPreviewOrderResponse = `{
+  "amount": {
+    "amount": "1,895.20 USD (10 Shares)",
+    "commission": "1.00 - 1.25 USD",
+    "total": "1,896.20 USD"
+  },
+  "equity": {"current": "1,000,000", "change": "-1", "after": "999,999"},
+  "initial": {"current": "25,000", "change": "379", "after": "25,379"},
+  "maintenance": {"current": "23,000", "change": "345", "after": "23,345"},
+  "position": {"current": "0", "change": "10", "after": "10"},
+  "warn": "21/You are trying to submit an order without having market data for this instrument.",
+  "error": null
+}`
+
+func TestIbkrWebClient_PreviewOrder(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/api/iserver/account/1234/orders/whatif", r.URL.Path)
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, testPreviewOrderResponse)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	rsp, err := client.PreviewOrder("1234", testMarketOrder)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1895.20, rsp.Amount)
+	assert.Equal(t, 1896.20, rsp.Total)
+	assert.Equal(t, "USD", rsp.Currency)
+	assert.Equal(t, 1.0, rsp.CommissionMin)
+	assert.Equal(t, 1.25, rsp.CommissionMax)
+	assert.Equal(t, PreviewChange{Current: 1000000, Change: -1, After: 999999}, rsp.EquityWithLoan)
+	assert.Equal(t, PreviewChange{Current: 25000, Change: 379, After: 25379}, rsp.InitialMargin)
+	assert.Equal(t, PreviewChange{Current: 23000, Change: 345, After: 23345}, rsp.MaintenanceMargin)
+	assert.Equal(t, PreviewChange{Current: 0, Change: 10, After: 10}, rsp.Position)
+	assert.Contains(t, rsp.Warning, "market data")
+}
+
+func TestIbkrWebClient_PreviewOrderError(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `{"error": "Order size exceeds available funds"}`)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	rsp, err := client.PreviewOrder("1234", testMarketOrder)
+
+	assert.Nil(t, rsp)
+	assert.ErrorIs(t, err, ErrOrderRejected)
+}
+
+func Test_parsePreviewAmounts(t *testing.T) {
+	amounts, currency, err := parsePreviewAmounts("23,000 USD (100 Shares)")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{23000}, amounts)
+	assert.Equal(t, "USD", currency)
+
+	amounts, currency, err = parsePreviewAmounts("")
+	assert.NoError(t, err)
+	assert.Empty(t, amounts)
+	assert.Empty(t, currency)
+
+	_, _, err = parsePreviewAmounts("N/A")
+	assert.Error(t, err)
+}
+
+var testOrderStatusResponse = `{
+  "sub_type": null,
+  "request_id": "209",
+  "server_id": "0",
+  "order_id": 1799796559,
+  "conidex": "265598",
+  "conid": 265598,
+  "symbol": "AAPL",
+  "side": "S",
+  "contract_description_1": "AAPL",
+  "listing_exchange": "NASDAQ.NMS",
+  "company_name": "APPLE INC",
+  "size": "0.0",
+  "total_size": "5.0",
+  "currency": "USD",
+  "account": "U1234567",
+  "order_type": "MARKET",
+  "cum_fill": "5.0",
+  "order_status": "Filled",
+  "order_ccp_status": "2",
+  "order_status_description": "Order Filled",
+  "tif": "DAY",
+  "order_not_editable": true,
+  "editable_fields": "",
+  "cannot_cancel_order": true,
+  "sec_type": "STK",
+  "order_description_with_contract": "Sold 5.00 AAPL Market, Day",
+  "size_and_fills": "5",
+  "average_price": "192.26",
+  "order_time": "231211180049"
+}`
+
+var testTradesResponse = `[
+  {
+    "execution_id": "0000e0d5.6576fd38.01.01",
+    "symbol": "AAPL",
+    "side": "S",
+    "order_description": "Sold 5 @ 192.26 on ISLAND",
+    "trade_time": "20231211-18:00:49",
+    "trade_time_r": 1702317649000,
+    "size": 5,
+    "price": "192.26",
+    "order_ref": "Order123",
+    "exchange": "ISLAND",
+    "commission": "1.01",
+    "net_amount": 961.3,
+    "account": "U1234567",
+    "sec_type": "STK",
+    "listing_exchange": "NASDAQ.NMS",
+    "conid": 265598
+  },
+  {
+    "execution_id": "0000e0d5.6576fd38.01.02",
+    "symbol": "AAPL",
+    "side": "B",
+    "trade_time": "20231212-14:30:00",
+    "size": "1,000",
+    "price": "190.5",
+    "commission": "2",
+    "net_amount": 190500,
+    "conid": 265598
+  }
+]`
+
+func TestIbkrWebClient_GetOrderStatus(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/api/iserver/account/order/status/1799796559", r.URL.Path)
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, testOrderStatusResponse)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	rsp, err := client.GetOrderStatus("1799796559")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1799796559), rsp.OrderID)
+	assert.Equal(t, "Filled", rsp.Status)
+	assert.Equal(t, 5.0, rsp.TotalSize)
+	assert.Equal(t, 5.0, rsp.FilledSize)
+	assert.Equal(t, 0.0, rsp.RemainingSize)
+	assert.Equal(t, 192.26, rsp.AveragePrice)
+	assert.False(t, rsp.Editable)
+	assert.False(t, rsp.Cancellable)
+	assert.Equal(t, time.Date(2023, 12, 11, 18, 0, 49, 0, time.UTC), rsp.OrderTime)
+}
+
+func TestIbkrWebClient_GetTrades(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/api/iserver/account/trades", r.URL.Path)
+		assert.Equal(t, "3", r.URL.Query().Get("days"))
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, testTradesResponse)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	rsp, err := client.GetTrades(3)
+
+	assert.NoError(t, err)
+	assert.Len(t, rsp, 2)
+
+	assert.Equal(t, "0000e0d5.6576fd38.01.01", rsp[0].ExecutionID)
+	assert.Equal(t, 5.0, rsp[0].Size)
+	assert.Equal(t, 192.26, rsp[0].Price)
+	assert.Equal(t, 1.01, rsp[0].Commission)
+	assert.Equal(t, 961.3, rsp[0].NetAmount)
+	assert.Equal(t, "Order123", rsp[0].OrderRef)
+	assert.Equal(t, time.Date(2023, 12, 11, 18, 0, 49, 0, time.UTC), rsp[0].TradeTime)
+
+	assert.Equal(t, 1000.0, rsp[1].Size)
+	assert.Equal(t, time.Date(2023, 12, 12, 14, 30, 0, 0, time.UTC), rsp[1].TradeTime)
+
+	_, err = client.GetTrades(MaxTradeDays + 1)
+	assert.Error(t, err)
+}
+
+func TestIbkrWebClient_PlaceOrderClientOrderID(t *testing.T) {
+	var sent string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody PlaceOrderRequest
+		err := json.NewDecoder(r.Body).Decode(&reqBody)
+		assert.NoError(t, err)
+		sent = reqBody.Orders[0].ClientOrderID
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, testPlaceOrderResponsePlain)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	rsp, err := client.PlaceOrder("1234", testMarketOrder)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, sent)
+	assert.Equal(t, sent, rsp.ClientOrderID)
+}
+
+// fails the first order submission with a server error, then reports the order as live from the
+// second live orders request when placed is set.
+func newTestIdempotentOrderServer(t *testing.T, placed bool, submissions *[]string) *httptest.Server {
+	lookups := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/api/iserver/account/1234/orders":
+			var reqBody PlaceOrderRequest
+			json.NewDecoder(r.Body).Decode(&reqBody)
+			*submissions = append(*submissions, reqBody.Orders[0].ClientOrderID)
+
+			if len(*submissions) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, testPlaceOrderResponsePlain)
+		case "/v1/api/iserver/account/orders":
+			lookups++
+
+			liveOrders := LiveOrdersResponse{}
+			if placed && lookups > 1 {
+				liveOrders.Orders = []OrderStatus{{OrderID: 987, Status: "PreSubmitted", OrderRef: (*submissions)[0]}}
+			}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(liveOrders)
+		}
+	}))
+}
+
+func TestIbkrWebClient_PlaceOrderIdempotentFound(t *testing.T) {
+	defer func(delay time.Duration) { LiveOrderLookupDelay = delay }(LiveOrderLookupDelay)
+	LiveOrderLookupDelay = 0
+
+	var submissions []string
+	mockServer := newTestIdempotentOrderServer(t, true, &submissions)
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+
+	rsp, err := client.PlaceOrderIdempotent("1234", testMarketOrder)
+
+	assert.NoError(t, err)
+	assert.Len(t, submissions, 1)
+	assert.Equal(t, "987", rsp.ID)
+	assert.Equal(t, "PreSubmitted", rsp.Status)
+	assert.Equal(t, submissions[0], rsp.ClientOrderID)
+}
+
+func TestIbkrWebClient_PlaceOrderIdempotentResubmit(t *testing.T) {
+	defer func(delay time.Duration) { LiveOrderLookupDelay = delay }(LiveOrderLookupDelay)
+	LiveOrderLookupDelay = 0
+
+	var submissions []string
+	mockServer := newTestIdempotentOrderServer(t, false, &submissions)
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+
+	order := testMarketOrder
+	order.ClientOrderID = "fixed-coid"
+
+	rsp, err := client.PlaceOrderIdempotent("1234", order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fixed-coid", "fixed-coid"}, submissions)
+	assert.Equal(t, "1234567890", rsp.ID)
+	assert.Equal(t, "fixed-coid", rsp.ClientOrderID)
+}
+
+func TestIbkrWebClient_PlaceOrderIdempotentRejected(t *testing.T) {
+	submissions := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		submissions++
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, testPlaceOrderResponseReject)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	rsp, err := client.PlaceOrderIdempotent("1234", testMarketOrder)
+
+	assert.Nil(t, rsp)
+	assert.ErrorIs(t, err, ErrOrderRejected)
+	assert.Equal(t, 1, submissions)
+}
+
+func Test_isAmbiguousOrderError(t *testing.T) {
+	assert.True(t, isAmbiguousOrderError(&url.Error{Op: "Post", URL: "/orders", Err: io.ErrUnexpectedEOF}))
+	assert.True(t, isAmbiguousOrderError(&APIError{StatusCode: http.StatusBadGateway}))
+	assert.True(t, isAmbiguousOrderError(io.ErrUnexpectedEOF))
+
+	var parsed map[string]any
+	assert.False(t, isAmbiguousOrderError(json.Unmarshal([]byte("not json"), &parsed)))
+	assert.False(t, isAmbiguousOrderError(&APIError{StatusCode: http.StatusBadRequest}))
+	assert.False(t, isAmbiguousOrderError(ErrOrderRejected))
+	assert.False(t, isAmbiguousOrderError(fmt.Errorf("no order response returned")))
+}
+
+func TestIbkrWebClient_SuppressMessageIDs(t *testing.T) {
+	var paths []string
+	var suppressed [][]string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		paths = append(paths, r.URL.Path)
+
+		if r.URL.Path == "/v1/api/iserver/questions/suppress" {
+			var reqBody SupressMessagesRequest
+			err := json.NewDecoder(r.Body).Decode(&reqBody)
+			assert.NoError(t, err)
+			suppressed = append(suppressed, reqBody.MessageIDs)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `{"status": "submitted"}`)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+
+	err := client.SuppressMessageIDs([]string{MessageIdPricePercentageConstraint, MessageIdSizeLimit})
+	assert.NoError(t, err)
+
+	err = client.SuppressMessages()
+	assert.NoError(t, err)
+
+	err = client.ResetSuppressedMessages()
+	assert.NoError(t, err)
+
+	err = client.SuppressMessageIDs(nil)
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{
+		"/v1/api/iserver/questions/suppress",
+		"/v1/api/iserver/questions/suppress",
+		"/v1/api/iserver/questions/suppress/reset",
+	}, paths)
+	assert.Equal(t, [][]string{{"o163", "o383"}, MessagesToSupress}, suppressed)
+}
+
+func TestDescribeMessageID(t *testing.T) {
+	assert.NotEmpty(t, DescribeMessageID(MessageIdStopOrderTypes))
+	assert.Empty(t, DescribeMessageID("o0"))
+
+	for id, description := range MessageIdDescriptions {
+		assert.NotEmpty(t, description, id)
+	}
+}
@@ -1,6 +1,7 @@
 package ibkr
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 )
@@ -20,13 +21,17 @@ type PortfolioSubaccount struct {
 }
 
 func (c *IbkrWebClient) GetPortfolioSubaccounts() ([]PortfolioSubaccount, error) {
-	response, err := c.Get("/portfolio/subaccounts", nil)
+	return c.GetPortfolioSubaccountsCtx(context.Background())
+}
+
+func (c *IbkrWebClient) GetPortfolioSubaccountsCtx(ctx context.Context) ([]PortfolioSubaccount, error) {
+	response, err := c.GetCtx(ctx, "/portfolio/subaccounts", nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("get portfolio subaccounts bad statusCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
 	var responseStruct []PortfolioSubaccount
@@ -56,13 +61,17 @@ type AccountLedger struct {
 }
 
 func (c *IbkrWebClient) GetPortfolioAccountLedger(acctId string) (*PortfolioAccountLedger, error) {
-	response, err := c.Get(fmt.Sprintf("/portfolio/%s/ledger", acctId), nil)
+	return c.GetPortfolioAccountLedgerCtx(context.Background(), acctId)
+}
+
+func (c *IbkrWebClient) GetPortfolioAccountLedgerCtx(ctx context.Context, acctId string) (*PortfolioAccountLedger, error) {
+	response, err := c.GetCtx(ctx, fmt.Sprintf("/portfolio/%s/ledger", acctId), nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad portfolio account ledger statusCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
 	var responseStruct PortfolioAccountLedger
@@ -95,13 +104,17 @@ type Position struct {
 }
 
 func (c *IbkrWebClient) GetPositions(acctId string, page int32) ([]Position, error) {
-	response, err := c.Get(fmt.Sprintf("/portfolio/%s/positions/%d", acctId, page), nil)
+	return c.GetPositionsCtx(context.Background(), acctId, page)
+}
+
+func (c *IbkrWebClient) GetPositionsCtx(ctx context.Context, acctId string, page int32) ([]Position, error) {
+	response, err := c.GetCtx(ctx, fmt.Sprintf("/portfolio/%s/positions/%d", acctId, page), nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad get positions statusCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
 	var responseStruct []Position
@@ -0,0 +1,216 @@
+package ibkr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// per endpoint limits documented by ibkr. path segments wrapped in braces match any single
+// segment and a trailing * matches the remainder of the path.
+var IbkrEndpointRateLimits = map[string]RateLimit{
+	"/iserver/marketdata/snapshot":     {Requests: 10, Per: time.Second},
+	"/iserver/scanner/params":          {Requests: 1, Per: 15 * time.Minute},
+	"/iserver/scanner/run":             {Requests: 1, Per: time.Second},
+	"/iserver/trades":                  {Requests: 1, Per: 5 * time.Second},
+	"/iserver/account/orders":          {Requests: 1, Per: 5 * time.Second},
+	"/iserver/account/trades":          {Requests: 1, Per: 5 * time.Second},
+	"/iserver/account/pnl/partitioned": {Requests: 1, Per: 5 * time.Second},
+	"/portfolio/accounts":              {Requests: 1, Per: 5 * time.Second},
+	"/portfolio/subaccounts":           {Requests: 1, Per: 5 * time.Second},
+	"/pa/performance":                  {Requests: 1, Per: 15 * time.Minute},
+	"/pa/summary":                      {Requests: 1, Per: 15 * time.Minute},
+	"/pa/transactions":                 {Requests: 1, Per: 15 * time.Minute},
+	"/fyi/*":                           {Requests: 1, Per: time.Second},
+	"/sso/validate":                    {Requests: 1, Per: time.Minute},
+	"/tickle":                          {Requests: 1, Per: time.Second},
+}
+
+type RateLimitPolicy int
+
+const (
+	RateLimitPolicyBlock RateLimitPolicy = iota
+	RateLimitPolicyFailFast
+)
+
+type tokenBucket struct {
+	capacity float64
+	rate     float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	capacity := float64(limit.Requests)
+	return &tokenBucket{
+		capacity: capacity,
+		rate:     capacity / limit.Per.Seconds(),
+		tokens:   capacity,
+		last:     time.Now(),
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// time until a token would be available if one were taken now. tokens may go negative to
+// represent reservations made by blocked callers.
+func (b *tokenBucket) delay() time.Duration {
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+type endpointBucket struct {
+	pattern []string
+	bucket  *tokenBucket
+}
+
+type RateLimiter struct {
+	Policy    RateLimitPolicy
+	mu        sync.Mutex
+	global    *tokenBucket
+	endpoints []endpointBucket
+}
+
+func NewRateLimiter(global RateLimit, endpoints map[string]RateLimit) *RateLimiter {
+	limiter := &RateLimiter{
+		Policy: RateLimitPolicyBlock,
+		global: newTokenBucket(global),
+	}
+
+	for path, limit := range endpoints {
+		limiter.endpoints = append(limiter.endpoints, endpointBucket{
+			pattern: splitPath(path),
+			bucket:  newTokenBucket(limit),
+		})
+	}
+
+	return limiter
+}
+
+func NewDefaultRateLimiter() *RateLimiter {
+	return NewRateLimiter(
+		RateLimit{Requests: IbkrGlobalRateLimit, Per: time.Second},
+		IbkrEndpointRateLimits,
+	)
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func matchPath(pattern []string, segments []string) bool {
+	for i, part := range pattern {
+		if part == "*" && i == len(pattern)-1 {
+			return len(segments) >= len(pattern)
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			continue
+		}
+		if part != segments[i] {
+			return false
+		}
+	}
+
+	return len(pattern) == len(segments)
+}
+
+func (r *RateLimiter) bucketsFor(path string) []*tokenBucket {
+	buckets := []*tokenBucket{r.global}
+
+	segments := splitPath(path)
+	for _, endpoint := range r.endpoints {
+		if matchPath(endpoint.pattern, segments) {
+			buckets = append(buckets, endpoint.bucket)
+		}
+	}
+
+	return buckets
+}
+
+// takes a token for the path without waiting, returning false if either the global or
+// endpoint limit has been reached.
+func (r *RateLimiter) Allow(path string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	buckets := r.bucketsFor(path)
+
+	for _, bucket := range buckets {
+		bucket.refill(now)
+		if bucket.tokens < 1 {
+			return false
+		}
+	}
+
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+
+	return true
+}
+
+// blocks until a token for the path is available or the context is done.
+func (r *RateLimiter) Wait(ctx context.Context, path string) error {
+	r.mu.Lock()
+
+	now := time.Now()
+	buckets := r.bucketsFor(path)
+
+	var wait time.Duration
+	for _, bucket := range buckets {
+		bucket.refill(now)
+		bucket.tokens--
+		wait = max(wait, bucket.delay())
+	}
+
+	r.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.mu.Lock()
+		for _, bucket := range buckets {
+			bucket.tokens = min(bucket.capacity, bucket.tokens+1)
+		}
+		r.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (r *RateLimiter) acquire(ctx context.Context, path string) error {
+	if r.Policy == RateLimitPolicyFailFast {
+		if !r.Allow(path) {
+			return fmt.Errorf("%w: client limit reached for %s", ErrRateLimited, path)
+		}
+		return nil
+	}
+
+	return r.Wait(ctx, path)
+}
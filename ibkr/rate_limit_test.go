@@ -0,0 +1,116 @@
+package ibkr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_matchPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{name: "exact", pattern: "/tickle", path: "/tickle", expected: true},
+		{name: "different", pattern: "/tickle", path: "/logout", expected: false},
+		{name: "longer path", pattern: "/iserver/account/orders", path: "/iserver/account/1234/orders", expected: false},
+		{name: "placeholder", pattern: "/iserver/account/{acctId}/orders", path: "/iserver/account/1234/orders", expected: true},
+		{name: "wildcard", pattern: "/fyi/*", path: "/fyi/settings/abc", expected: true},
+		{name: "wildcard needs segment", pattern: "/fyi/*", path: "/fyi", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchPath(splitPath(tt.pattern), splitPath(tt.path)))
+		})
+	}
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter := NewRateLimiter(
+		RateLimit{Requests: 10, Per: time.Second},
+		map[string]RateLimit{"/tickle": {Requests: 1, Per: time.Second}},
+	)
+
+	assert.True(t, limiter.Allow("/tickle"))
+	assert.False(t, limiter.Allow("/tickle"))
+	assert.True(t, limiter.Allow("/iserver/accounts"))
+}
+
+func TestRateLimiter_WaitConcurrent(t *testing.T) {
+	limiter := NewRateLimiter(
+		RateLimit{Requests: 100, Per: time.Second},
+		map[string]RateLimit{"/test-endpoint": {Requests: 1, Per: 50 * time.Millisecond}},
+	)
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, limiter.Wait(context.Background(), "/test-endpoint"))
+		}()
+	}
+	wg.Wait()
+
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestRateLimiter_WaitCancelled(t *testing.T) {
+	limiter := NewRateLimiter(
+		RateLimit{Requests: 1, Per: time.Minute},
+		nil,
+	)
+
+	assert.NoError(t, limiter.Wait(context.Background(), "/test-endpoint"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := limiter.Wait(ctx, "/test-endpoint")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestIbkrWebClient_RateLimitFailFast(t *testing.T) {
+	var hits atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = NewRateLimiter(
+		RateLimit{Requests: 50, Per: time.Second},
+		map[string]RateLimit{"/tickle": {Requests: 1, Per: time.Minute}},
+	)
+	client.RateLimiter.Policy = RateLimitPolicyFailFast
+
+	var failures atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Post("/tickle", nil, nil)
+			if err != nil {
+				assert.ErrorIs(t, err, ErrRateLimited)
+				failures.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), hits.Load())
+	assert.Equal(t, int32(4), failures.Load())
+}
@@ -0,0 +1,124 @@
+package ibkr
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      bool
+	// non idempotent requests (anything other than GET) such as placing an order are only
+	// replayed when explicitly enabled.
+	RetryNonIdempotent bool
+}
+
+func NewDefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:        3,
+		BaseDelay:          250 * time.Millisecond,
+		MaxDelay:           5 * time.Second,
+		Jitter:             true,
+		RetryNonIdempotent: false,
+	}
+}
+
+func (p *RetryPolicy) allowsMethod(method string) bool {
+	return method == methodGet || p.RetryNonIdempotent
+}
+
+func (p *RetryPolicy) backoff(attempt int, response *clientResponse) time.Duration {
+	if response != nil {
+		retryAfter, ok := parseRetryAfter(response.header.Get("Retry-After"))
+		if ok {
+			return min(retryAfter, p.MaxDelay)
+		}
+	}
+
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter && delay > 0 {
+		half := delay / 2
+		delay = half + time.Duration(rand.Int63n(int64(half)+1))
+	}
+
+	return delay
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+func isRetryableResponse(response *clientResponse) bool {
+	if response.statusCode == http.StatusTooManyRequests || response.statusCode >= http.StatusInternalServerError {
+		return true
+	}
+
+	// the gateway occasionally returns an empty body with a 200
+	return response.statusCode == http.StatusOK && len(response.bytes) == 0
+}
+
+func isRetryableError(err error) bool {
+	if errors.Is(err, ErrRateLimited) {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (c *IbkrWebClient) shouldRetry(
+	ctx context.Context,
+	method string,
+	attempt int,
+	response *clientResponse,
+	err error,
+) bool {
+	if c.RetryPolicy == nil || attempt >= c.RetryPolicy.MaxAttempts {
+		return false
+	}
+
+	if ctx.Err() != nil || !c.RetryPolicy.allowsMethod(method) {
+		return false
+	}
+
+	if err != nil {
+		return isRetryableError(err)
+	}
+
+	return isRetryableResponse(response)
+}
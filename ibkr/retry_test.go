@@ -0,0 +1,136 @@
+package ibkr
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    10 * time.Millisecond,
+	}
+}
+
+func newFlakyServer(failures int32, failStatus int, hits *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= failures {
+			w.WriteHeader(failStatus)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `{"message": "success"}`)
+	}))
+}
+
+func TestIbkrWebClient_RetryGet(t *testing.T) {
+	var hits atomic.Int32
+	mockServer := newFlakyServer(2, http.StatusServiceUnavailable, &hits)
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RetryPolicy = newTestRetryPolicy()
+
+	rsp, err := client.Get("/test-endpoint", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.statusCode)
+	assert.Equal(t, int32(3), hits.Load())
+}
+
+func TestIbkrWebClient_RetryGetExhausted(t *testing.T) {
+	var hits atomic.Int32
+	mockServer := newFlakyServer(5, http.StatusBadGateway, &hits)
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RetryPolicy = newTestRetryPolicy()
+
+	rsp, err := client.Get("/test-endpoint", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, rsp.statusCode)
+	assert.Equal(t, int32(3), hits.Load())
+}
+
+func TestIbkrWebClient_RetryEmptyBody(t *testing.T) {
+	var hits atomic.Int32
+	mockServer := newFlakyServer(1, http.StatusOK, &hits)
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RetryPolicy = newTestRetryPolicy()
+
+	rsp, err := client.Get("/test-endpoint", nil)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rsp.bytes)
+	assert.Equal(t, int32(2), hits.Load())
+}
+
+func TestIbkrWebClient_NoRetryPost(t *testing.T) {
+	var hits atomic.Int32
+	mockServer := newFlakyServer(1, http.StatusServiceUnavailable, &hits)
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RetryPolicy = newTestRetryPolicy()
+
+	_, err := client.PlaceOrder("1234", testMarketOrder)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), hits.Load())
+}
+
+func TestIbkrWebClient_RetryPostOptIn(t *testing.T) {
+	var hits atomic.Int32
+	mockServer := newFlakyServer(1, http.StatusTooManyRequests, &hits)
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RetryPolicy = newTestRetryPolicy()
+	client.RetryPolicy.RetryNonIdempotent = true
+
+	rsp, err := client.Post("/test-endpoint", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.statusCode)
+	assert.Equal(t, int32(2), hits.Load())
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	delay, ok := parseRetryAfter("2")
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, delay)
+
+	delay, ok = parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.Greater(t, delay, 59*time.Minute)
+
+	_, ok = parseRetryAfter("")
+	assert.False(t, ok)
+
+	_, ok = parseRetryAfter("soon")
+	assert.False(t, ok)
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1, nil))
+	assert.Equal(t, 400*time.Millisecond, policy.backoff(3, nil))
+	assert.Equal(t, time.Second, policy.backoff(10, nil))
+
+	response := &clientResponse{header: http.Header{"Retry-After": []string{"3"}}}
+	assert.Equal(t, time.Second, policy.backoff(1, response))
+
+	policy.Jitter = true
+	for i := 0; i < 10; i++ {
+		delay := policy.backoff(2, nil)
+		assert.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		assert.LessOrEqual(t, delay, 200*time.Millisecond)
+	}
+}
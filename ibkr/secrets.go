@@ -0,0 +1,79 @@
+package ibkr
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// resolves a named secret such as a pem encoded key or access token. additional providers
+// (vaults, cloud secret managers) can be plugged in with RegisterSecretSource.
+type SecretSource interface {
+	ReadSecret(name string) ([]byte, error)
+}
+
+type EnvSecretSource struct{}
+
+func (EnvSecretSource) ReadSecret(name string) ([]byte, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s not set", name)
+	}
+
+	return []byte(value), nil
+}
+
+type FileSecretSource struct{}
+
+func (FileSecretSource) ReadSecret(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+var (
+	secretSourcesMu sync.RWMutex
+	secretSources   = map[string]SecretSource{
+		"env":  EnvSecretSource{},
+		"file": FileSecretSource{},
+	}
+)
+
+func RegisterSecretSource(scheme string, source SecretSource) {
+	secretSourcesMu.Lock()
+	defer secretSourcesMu.Unlock()
+
+	secretSources[scheme] = source
+}
+
+// resolves a secret reference of the form "scheme:name", e.g. "env:IBKR_ACCESS_TOKEN" or
+// "file:/run/secrets/signing.pem". references without a registered scheme are read with the
+// default scheme, or returned as is when no default is given.
+func ResolveSecret(ref string, defaultScheme string) ([]byte, error) {
+	scheme, name, found := strings.Cut(ref, ":")
+	if found {
+		source, ok := lookupSecretSource(scheme)
+		if ok {
+			return source.ReadSecret(name)
+		}
+	}
+
+	if defaultScheme == "" {
+		return []byte(ref), nil
+	}
+
+	source, ok := lookupSecretSource(defaultScheme)
+	if !ok {
+		return nil, fmt.Errorf("unknown secret source: %s", defaultScheme)
+	}
+
+	return source.ReadSecret(ref)
+}
+
+// sources are read outside the registry lock so they may block or register other sources.
+func lookupSecretSource(scheme string) (SecretSource, bool) {
+	secretSourcesMu.RLock()
+	defer secretSourcesMu.RUnlock()
+
+	source, ok := secretSources[scheme]
+	return source, ok
+}
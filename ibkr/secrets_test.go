@@ -0,0 +1,155 @@
+package ibkr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSecretSource map[string]string
+
+func (s testSecretSource) ReadSecret(name string) ([]byte, error) {
+	value, ok := s[name]
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found", name)
+	}
+	return []byte(value), nil
+}
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("IBKR_TEST_SECRET", "from-env")
+
+	path := filepath.Join(t.TempDir(), "secret")
+	assert.NoError(t, os.WriteFile(path, []byte("from-file"), 0600))
+
+	RegisterSecretSource("test", testSecretSource{"name": "from-test"})
+
+	tests := []struct {
+		name          string
+		ref           string
+		defaultScheme string
+		expected      string
+		err           bool
+	}{
+		{name: "env", ref: "env:IBKR_TEST_SECRET", expected: "from-env"},
+		{name: "missing env", ref: "env:IBKR_TEST_MISSING", err: true},
+		{name: "file", ref: "file:" + path, expected: "from-file"},
+		{name: "registered source", ref: "test:name", expected: "from-test"},
+		{name: "literal", ref: "plainvalue", expected: "plainvalue"},
+		{name: "unknown scheme literal", ref: "abc:def", expected: "abc:def"},
+		{name: "default scheme", ref: path, defaultScheme: "file", expected: "from-file"},
+		{name: "explicit scheme overrides default", ref: "env:IBKR_TEST_SECRET", defaultScheme: "file", expected: "from-env"},
+		{name: "unknown default scheme", ref: "value", defaultScheme: "missing", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := ResolveSecret(tt.ref, tt.defaultScheme)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(value))
+		})
+	}
+}
+
+type registeringSecretSource struct{}
+
+func (registeringSecretSource) ReadSecret(name string) ([]byte, error) {
+	RegisterSecretSource("registered", testSecretSource{"name": "from-registered"})
+	return []byte(name), nil
+}
+
+func TestResolveSecret_SourceRegistersSource(t *testing.T) {
+	RegisterSecretSource("registering", registeringSecretSource{})
+
+	secret, err := ResolveSecret("registering:name", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "name", string(secret))
+
+	secret, err = ResolveSecret("registered:name", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "from-registered", string(secret))
+}
+
+func TestNewIbkrOAuthContextFromEnv(t *testing.T) {
+	t.Setenv(EnvConsumerKey, "TESTCONS")
+	t.Setenv(EnvAccessToken, "testtoken")
+	t.Setenv(EnvAccessSecret, "testsecret")
+	t.Setenv(EnvSigningKeyPem, testRsaPemContents)
+	t.Setenv(EnvEncryptionKeyPem, testRsaPemContents)
+	t.Setenv(EnvDhParamsPem, testDhParamsContents)
+
+	oauth, err := NewIbkrOAuthContextFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, "TESTCONS", oauth.ConsumerKey)
+	assert.Equal(t, "testtoken", oauth.AccessToken)
+	assert.Equal(t, "testsecret", oauth.AccessSecret)
+	assert.NotNil(t, oauth.SigningKey)
+	assert.NotNil(t, oauth.EncryptionKey)
+	assert.NotNil(t, oauth.DhParams)
+}
+
+func TestNewIbkrOAuthContextFromEnvMissing(t *testing.T) {
+	t.Setenv(EnvConsumerKey, "")
+	os.Unsetenv(EnvConsumerKey)
+
+	_, err := NewIbkrOAuthContextFromEnv()
+	assert.ErrorContains(t, err, "customer_key")
+}
+
+func TestNewIbkrOAuthContextFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	keyPath := filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(keyPath, []byte(testRsaPemContents), 0600))
+
+	t.Setenv("IBKR_TEST_DH_PARAMS", testDhParamsContents)
+	t.Setenv("IBKR_TEST_ACCESS_SECRET", "testsecret")
+
+	credentials := fmt.Sprintf(`customer_key: TESTCONS
+access_token: testtoken
+access_secret: env:IBKR_TEST_ACCESS_SECRET
+signing_key_path: %s
+encryption_key_path: file:%s
+dh_params_path: env:IBKR_TEST_DH_PARAMS
+`, keyPath, keyPath)
+
+	credentialsPath := filepath.Join(dir, "credentials.yml")
+	assert.NoError(t, os.WriteFile(credentialsPath, []byte(credentials), 0600))
+
+	oauth, err := NewIbkrOAuthContextFromFile(credentialsPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "TESTCONS", oauth.ConsumerKey)
+	assert.Equal(t, "testsecret", oauth.AccessSecret)
+	assert.NotNil(t, oauth.DhParams)
+}
+
+func TestNewIbkrOAuthContextFromEnvEncryptedKey(t *testing.T) {
+	t.Setenv(EnvConsumerKey, "TESTCONS")
+	t.Setenv(EnvAccessToken, "testtoken")
+	t.Setenv(EnvAccessSecret, "testsecret")
+	t.Setenv(EnvSigningKeyPem, testEncryptedPkcs8PemContents)
+	t.Setenv(EnvSigningKeyPassphrase, "testpass")
+	t.Setenv(EnvEncryptionKeyPem, testPkcs1PemContents)
+	t.Setenv(EnvDhParamsPem, testDhParamsContents)
+
+	oauth, err := NewIbkrOAuthContextFromEnv()
+	assert.NoError(t, err)
+	assert.True(t, oauth.SigningKey.Equal(oauth.EncryptionKey))
+
+	// only a trailing newline is stripped, other whitespace is part of the passphrase
+	t.Setenv(EnvSigningKeyPassphrase, "testpass\r\n")
+	_, err = NewIbkrOAuthContextFromEnv()
+	assert.NoError(t, err)
+
+	t.Setenv(EnvSigningKeyPassphrase, " testpass")
+	_, err = NewIbkrOAuthContextFromEnv()
+	assert.Error(t, err)
+}
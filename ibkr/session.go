@@ -1,6 +1,7 @@
 package ibkr
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 )
@@ -28,13 +29,17 @@ type LogoutResponse struct {
 }
 
 func (c *IbkrWebClient) Logout() error {
-	response, err := c.Post("/logout", nil, nil)
+	return c.LogoutCtx(context.Background())
+}
+
+func (c *IbkrWebClient) LogoutCtx(ctx context.Context) error {
+	response, err := c.PostCtx(ctx, "/logout", nil, nil)
 	if err != nil {
 		return err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return fmt.Errorf("bad logout statusCode: %v", response.statusCode)
+		return newResponseError(response)
 	}
 
 	var responseStruct LogoutResponse
@@ -60,18 +65,22 @@ type InitializeBrokerageSessionRequest struct {
 }
 
 func (c *IbkrWebClient) InitializeBrokerSession() (*AuthStatus, error) {
+	return c.InitializeBrokerSessionCtx(context.Background())
+}
+
+func (c *IbkrWebClient) InitializeBrokerSessionCtx(ctx context.Context) (*AuthStatus, error) {
 	requestBody := InitializeBrokerageSessionRequest{
 		Publish: true,
 		Compete: true,
 	}
 
-	response, err := c.Post("/iserver/auth/ssodh/init", nil, requestBody)
+	response, err := c.PostCtx(ctx, "/iserver/auth/ssodh/init", nil, requestBody)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad logout statusCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
 	var responseStruct AuthStatus
@@ -88,13 +97,17 @@ func (c *IbkrWebClient) InitializeBrokerSession() (*AuthStatus, error) {
 ******************************************************************************/
 
 func (c *IbkrWebClient) AuthStatus() (*AuthStatus, error) {
-	response, err := c.Post("/iserver/auth/status", nil, nil)
+	return c.AuthStatusCtx(context.Background())
+}
+
+func (c *IbkrWebClient) AuthStatusCtx(ctx context.Context) (*AuthStatus, error) {
+	response, err := c.PostCtx(ctx, "/iserver/auth/status", nil, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad logout statusCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
 	var responseStruct AuthStatus
@@ -128,13 +141,17 @@ type IServerDetails struct {
 }
 
 func (c *IbkrWebClient) Tickle() (*TickleResponse, error) {
-	response, err := c.Post("/tickle", nil, nil)
+	return c.TickleCtx(context.Background())
+}
+
+func (c *IbkrWebClient) TickleCtx(ctx context.Context) (*TickleResponse, error) {
+	response, err := c.PostCtx(ctx, "/tickle", nil, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if response.statusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad logout statusCode: %v", response.statusCode)
+		return nil, newResponseError(response)
 	}
 
 	var responseStruct TickleResponse
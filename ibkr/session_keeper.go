@@ -0,0 +1,216 @@
+package ibkr
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var DefaultSessionKeepaliveInterval = 60 * time.Second
+
+type SessionState int
+
+const (
+	SessionStateUnknown SessionState = iota
+	SessionStateHealthy
+	SessionStateCompeting
+	SessionStateDisconnected
+	SessionStateUnauthenticated
+	SessionStateError
+)
+
+func (s SessionState) String() string {
+	switch s {
+	case SessionStateHealthy:
+		return "healthy"
+	case SessionStateCompeting:
+		return "competing"
+	case SessionStateDisconnected:
+		return "disconnected"
+	case SessionStateUnauthenticated:
+		return "unauthenticated"
+	case SessionStateError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+type SessionEvent struct {
+	State    SessionState
+	Previous SessionState
+	Time     time.Time
+	Err      error
+}
+
+type SessionKeeper struct {
+	Interval time.Duration
+	client   *IbkrWebClient
+	events   chan SessionEvent
+	mu       sync.Mutex
+	state    SessionState
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewSessionKeeper(client *IbkrWebClient, interval time.Duration) *SessionKeeper {
+	if interval <= 0 {
+		interval = DefaultSessionKeepaliveInterval
+	}
+
+	return &SessionKeeper{
+		Interval: interval,
+		client:   client,
+		events:   make(chan SessionEvent, 16),
+		state:    SessionStateUnknown,
+	}
+}
+
+// state changes are published here. the channel is closed when the keeper is stopped.
+func (k *SessionKeeper) Events() <-chan SessionEvent {
+	return k.events
+}
+
+func (k *SessionKeeper) State() SessionState {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.state
+}
+
+func (k *SessionKeeper) Start() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+	k.done = make(chan struct{})
+
+	go k.run(ctx)
+}
+
+// stops the keepalive loop and closes the events channel. a stopped keeper cannot be restarted.
+func (k *SessionKeeper) Stop() {
+	k.mu.Lock()
+	cancel := k.cancel
+	done := k.done
+	k.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+func (k *SessionKeeper) run(ctx context.Context) {
+	defer close(k.done)
+	defer close(k.events)
+
+	ticker := time.NewTicker(k.Interval)
+	defer ticker.Stop()
+
+	for {
+		k.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (k *SessionKeeper) check(ctx context.Context) {
+	state, err := k.tickle(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	// a dropped brokerage connection is recovered the same way as a lost authentication
+	if state == SessionStateUnauthenticated || state == SessionStateDisconnected {
+		k.publish(state, err)
+
+		if k.client.VerboseLogging {
+			log.Printf("---- ibkr brokerage session %v, re-initializing", state)
+		}
+		state, err = k.initialize(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+
+	k.publish(state, err)
+}
+
+func (k *SessionKeeper) tickle(ctx context.Context) (SessionState, error) {
+	rsp, err := k.client.TickleCtx(ctx)
+	if err != nil {
+		if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrSessionNotAuthenticated) {
+			return SessionStateUnauthenticated, err
+		}
+		return SessionStateError, err
+	}
+
+	return sessionStateFromAuthStatus(&rsp.IServer.AuthStatus), nil
+}
+
+func (k *SessionKeeper) initialize(ctx context.Context) (SessionState, error) {
+	status, err := k.client.InitializeBrokerSessionCtx(ctx)
+	if err != nil {
+		return SessionStateError, err
+	}
+
+	state := sessionStateFromAuthStatus(status)
+	if state == SessionStateUnauthenticated || state == SessionStateDisconnected {
+		return state, ErrSessionNotAuthenticated
+	}
+
+	return state, nil
+}
+
+func sessionStateFromAuthStatus(status *AuthStatus) SessionState {
+	switch {
+	case !status.Connected:
+		return SessionStateDisconnected
+	case status.Competing:
+		return SessionStateCompeting
+	case !status.Authenticated:
+		return SessionStateUnauthenticated
+	default:
+		return SessionStateHealthy
+	}
+}
+
+func (k *SessionKeeper) publish(state SessionState, err error) {
+	k.mu.Lock()
+	previous := k.state
+	k.state = state
+	k.mu.Unlock()
+
+	if state == previous {
+		return
+	}
+
+	event := SessionEvent{
+		State:    state,
+		Previous: previous,
+		Time:     time.Now(),
+		Err:      err,
+	}
+
+	select {
+	case k.events <- event:
+	default:
+		if k.client.VerboseLogging {
+			log.Printf("---- ibkr session event dropped, channel full: %v -> %v", previous, state)
+		}
+	}
+}
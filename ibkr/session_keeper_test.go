@@ -0,0 +1,124 @@
+package ibkr
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testTickleResponseTemplate = `{
+  "session": "bb665d0f55b6289d70bc7380089fc96f",
+  "ssoExpires": 460311,
+  "collission": false,
+  "userId": 123456789,
+  "iserver": {
+    "authStatus": {
+      "authenticated": %v,
+      "competing": false,
+      "connected": true
+    }
+  }
+}`
+
+func TestSessionKeeper_ReinitializesSession(t *testing.T) {
+	var initialized atomic.Bool
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/api/tickle":
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, fmt.Sprintf(testTickleResponseTemplate, initialized.Load()))
+		case "/v1/api/iserver/auth/ssodh/init":
+			initialized.Store(true)
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, testInitializeBrokerageSessionResponse)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+	keeper := NewSessionKeeper(client, 10*time.Millisecond)
+	keeper.Start()
+
+	event := <-keeper.Events()
+	assert.Equal(t, SessionStateUnauthenticated, event.State)
+	assert.Equal(t, SessionStateUnknown, event.Previous)
+
+	event = <-keeper.Events()
+	assert.Equal(t, SessionStateHealthy, event.State)
+	assert.Equal(t, SessionStateUnauthenticated, event.Previous)
+	assert.NoError(t, event.Err)
+
+	keeper.Stop()
+
+	_, ok := <-keeper.Events()
+	assert.False(t, ok)
+	assert.Equal(t, SessionStateHealthy, keeper.State())
+}
+
+func TestSessionKeeper_ReinitializesDisconnectedSession(t *testing.T) {
+	var initialized atomic.Bool
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/api/tickle":
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, fmt.Sprintf(
+				`{"session": "bb665d0f55b6289d70bc7380089fc96f", "iserver": {"authStatus": {"authenticated": true, "competing": false, "connected": %v}}}`,
+				initialized.Load(),
+			))
+		case "/v1/api/iserver/auth/ssodh/init":
+			initialized.Store(true)
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, testInitializeBrokerageSessionResponse)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+	keeper := NewSessionKeeper(client, 10*time.Millisecond)
+	keeper.Start()
+	defer keeper.Stop()
+
+	event := <-keeper.Events()
+	assert.Equal(t, SessionStateDisconnected, event.State)
+
+	event = <-keeper.Events()
+	assert.Equal(t, SessionStateHealthy, event.State)
+	assert.Equal(t, SessionStateDisconnected, event.Previous)
+	assert.True(t, initialized.Load())
+}
+
+func TestSessionKeeper_TickleError(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+	keeper := NewSessionKeeper(client, 10*time.Millisecond)
+	keeper.Start()
+	defer keeper.Stop()
+
+	event := <-keeper.Events()
+	assert.Equal(t, SessionStateError, event.State)
+	assert.Error(t, event.Err)
+}
+
+func Test_sessionStateFromAuthStatus(t *testing.T) {
+	assert.Equal(t, SessionStateHealthy, sessionStateFromAuthStatus(&AuthStatus{Authenticated: true, Connected: true}))
+	assert.Equal(t, SessionStateCompeting, sessionStateFromAuthStatus(&AuthStatus{Authenticated: true, Connected: true, Competing: true}))
+	assert.Equal(t, SessionStateDisconnected, sessionStateFromAuthStatus(&AuthStatus{Authenticated: true}))
+	assert.Equal(t, SessionStateUnauthenticated, sessionStateFromAuthStatus(&AuthStatus{Connected: true}))
+}
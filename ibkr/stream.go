@@ -0,0 +1,405 @@
+package ibkr
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	DefaultStreamHeartbeatInterval = 25 * time.Second
+	DefaultStreamReconnectDelay    = time.Second
+	DefaultStreamMaxReconnectDelay = 30 * time.Second
+)
+
+const streamWriteTimeout = 10 * time.Second
+
+// ibkr only sends the fields that changed since the previous update for the conid. the numeric
+// accessors return false both when the field is missing from the update and when its value cannot
+// be parsed, so a false result does not mean the field was left out.
+type MarketDataUpdate struct {
+	ConID  int
+	Time   time.Time
+	Fields map[string]string
+}
+
+// returns false when the field is missing from the update or is not numeric.
+func (u *MarketDataUpdate) Float(field string) (float64, bool) {
+	value, ok := u.Fields[field]
+	if !ok {
+		return 0, false
+	}
+
+	parsed, _, err := parseMarketDataNumber(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return parsed, true
+}
+
+func (u *MarketDataUpdate) LastPrice() (float64, bool) {
+	return u.Float(MarketDataFieldLastPrice)
+}
+
+func (u *MarketDataUpdate) Bid() (float64, bool) {
+	return u.Float(MarketDataFieldBid)
+}
+
+func (u *MarketDataUpdate) Ask() (float64, bool) {
+	return u.Float(MarketDataFieldAsk)
+}
+
+func (u *MarketDataUpdate) BidSize() (float64, bool) {
+	return u.Float(MarketDataFieldBidSize)
+}
+
+func (u *MarketDataUpdate) AskSize() (float64, bool) {
+	return u.Float(MarketDataFieldAskSize)
+}
+
+func (u *MarketDataUpdate) Volume() (float64, bool) {
+	return u.Float(MarketDataFieldVolume)
+}
+
+// streams market data over the ibkr websocket. subscriptions are kept across reconnects and sent
+// again whenever the connection is re-established.
+type MarketDataStream struct {
+	HeartbeatInterval time.Duration
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
+	client            *IbkrWebClient
+	dialer            *websocket.Dialer
+	updates           chan MarketDataUpdate
+	mu                sync.Mutex
+	subscriptions     map[int][]string
+	conn              *websocket.Conn
+	writeMu           sync.Mutex
+	cancel            context.CancelFunc
+	done              chan struct{}
+}
+
+func NewMarketDataStream(client *IbkrWebClient) *MarketDataStream {
+	dialer := *websocket.DefaultDialer
+
+	// skip cert auth check if talking to gateway
+	if client.oauth == nil {
+		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &MarketDataStream{
+		HeartbeatInterval: DefaultStreamHeartbeatInterval,
+		ReconnectDelay:    DefaultStreamReconnectDelay,
+		MaxReconnectDelay: DefaultStreamMaxReconnectDelay,
+		client:            client,
+		dialer:            &dialer,
+		updates:           make(chan MarketDataUpdate, 256),
+		subscriptions:     map[int][]string{},
+	}
+}
+
+// updates are dropped rather than queued when the consumer falls behind. the channel is closed
+// when the stream is stopped.
+func (s *MarketDataStream) Updates() <-chan MarketDataUpdate {
+	return s.updates
+}
+
+func (s *MarketDataStream) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go s.run(ctx)
+}
+
+// closes the connection and the updates channel. a stopped stream cannot be restarted.
+func (s *MarketDataStream) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	done := s.done
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+// subscribes to the given snapshot fields for the conid. the subscription is sent immediately when
+// connected, otherwise once the connection is established.
+func (s *MarketDataStream) Subscribe(conId int, fields ...string) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("no fields to subscribe to for conid %d", conId)
+	}
+
+	s.mu.Lock()
+	s.subscriptions[conId] = slices.Clone(fields)
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	return s.writeSubscribe(conn, conId, fields)
+}
+
+func (s *MarketDataStream) Unsubscribe(conId int) error {
+	s.mu.Lock()
+	delete(s.subscriptions, conId)
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	return s.write(conn, fmt.Sprintf("umd+%d+{}", conId))
+}
+
+func (s *MarketDataStream) run(ctx context.Context) {
+	defer close(s.done)
+	defer close(s.updates)
+
+	delay := s.ReconnectDelay
+	for {
+		connected, err := s.connectAndServe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if connected {
+			delay = s.ReconnectDelay
+		}
+
+		if s.client.VerboseLogging {
+			log.Printf("---- ibkr market data stream disconnected, reconnecting in %v: %v", delay, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		delay = min(delay*2, s.MaxReconnectDelay)
+	}
+}
+
+func (s *MarketDataStream) websocketUrl() (string, error) {
+	base, err := url.Parse(s.client.BaseUrl)
+	if err != nil {
+		return "", err
+	}
+
+	wsUrl := base.ResolveReference(&url.URL{Path: "/v1/api/ws"})
+	switch wsUrl.Scheme {
+	case "https":
+		wsUrl.Scheme = "wss"
+	case "http":
+		wsUrl.Scheme = "ws"
+	}
+
+	// oauth sessions identify themselves with the access token
+	oauth, ok := s.client.oauth.(*IbkrOAuthContext)
+	if ok {
+		wsUrl.RawQuery = url.Values{"oauth_token": {oauth.AccessToken}}.Encode()
+	}
+
+	return wsUrl.String(), nil
+}
+
+// returns whether a connection was established along with the error that ended it.
+func (s *MarketDataStream) connectAndServe(ctx context.Context) (bool, error) {
+	tickle, err := s.client.TickleCtx(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	wsUrl, err := s.websocketUrl()
+	if err != nil {
+		return false, err
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", DefaultUserAgent)
+
+	conn, _, err := s.dialer.DialContext(ctx, wsUrl, header)
+	if err != nil {
+		return false, err
+	}
+
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// closing the connection unblocks the read loop when the stream is stopped
+	go func() {
+		<-connCtx.Done()
+		conn.Close()
+	}()
+
+	err = s.write(conn, fmt.Sprintf(`{"session":"%s"}`, tickle.Session))
+	if err != nil {
+		return true, err
+	}
+
+	s.mu.Lock()
+	s.conn = conn
+	subscriptions := make(map[int][]string, len(s.subscriptions))
+	for conId, fields := range s.subscriptions {
+		subscriptions[conId] = fields
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+
+	for conId, fields := range subscriptions {
+		err = s.writeSubscribe(conn, conId, fields)
+		if err != nil {
+			return true, err
+		}
+	}
+
+	go s.heartbeat(connCtx, conn)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(3 * s.HeartbeatInterval))
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+
+		s.handleMessage(message)
+	}
+}
+
+func (s *MarketDataStream) heartbeat(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(s.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := s.write(conn, "tic")
+		if err != nil {
+			if s.client.VerboseLogging {
+				log.Printf("---- ibkr market data stream heartbeat failed: %v", err)
+			}
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (s *MarketDataStream) writeSubscribe(conn *websocket.Conn, conId int, fields []string) error {
+	args, err := json.Marshal(map[string][]string{"fields": fields})
+	if err != nil {
+		return err
+	}
+
+	return s.write(conn, fmt.Sprintf("smd+%d+%s", conId, args))
+}
+
+func (s *MarketDataStream) write(conn *websocket.Conn, message string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return conn.WriteMessage(websocket.TextMessage, []byte(message))
+}
+
+func (s *MarketDataStream) handleMessage(message []byte) {
+	var raw map[string]json.RawMessage
+	if json.Unmarshal(message, &raw) != nil {
+		return
+	}
+
+	var topic string
+	json.Unmarshal(raw["topic"], &topic)
+
+	if !strings.HasPrefix(topic, "smd+") {
+		return
+	}
+
+	update, err := parseMarketDataUpdate(topic, raw)
+	if err != nil {
+		if s.client.VerboseLogging {
+			log.Printf("---- ibkr market data stream could not parse update: %v", err)
+		}
+		return
+	}
+
+	select {
+	case s.updates <- *update:
+	default:
+		if s.client.VerboseLogging {
+			log.Printf("---- ibkr market data update dropped, channel full: conid %d", update.ConID)
+		}
+	}
+}
+
+func parseMarketDataUpdate(topic string, raw map[string]json.RawMessage) (*MarketDataUpdate, error) {
+	conId, err := strconv.Atoi(strings.TrimPrefix(topic, "smd+"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid topic: %s", topic)
+	}
+
+	update := MarketDataUpdate{
+		ConID:  conId,
+		Time:   time.Now(),
+		Fields: map[string]string{},
+	}
+
+	var updated int64
+	if json.Unmarshal(raw["_updated"], &updated) == nil && updated > 0 {
+		update.Time = time.UnixMilli(updated)
+	}
+
+	// field ids are numeric, everything else is metadata
+	for key, value := range raw {
+		_, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+
+		var stringValue string
+		if json.Unmarshal(value, &stringValue) != nil {
+			stringValue = string(value)
+		}
+
+		update.Fields[key] = stringValue
+	}
+
+	return &update, nil
+}
@@ -0,0 +1,166 @@
+package ibkr
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// stands in for the ibkr websocket. each connection gets one tick per subscription and the first
+// connection is dropped afterwards to exercise reconnects.
+type testStreamServer struct {
+	mu          sync.Mutex
+	connections int
+	messages    []string
+}
+
+func (s *testStreamServer) record(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, message)
+}
+
+func (s *testStreamServer) received(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, message := range s.messages {
+		if strings.HasPrefix(message, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *testStreamServer) handler(t *testing.T) http.HandlerFunc {
+	upgrader := websocket.Upgrader{}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/api/tickle" {
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, fmt.Sprintf(testTickleResponseTemplate, true))
+			return
+		}
+
+		assert.Equal(t, "/v1/api/ws", r.URL.Path)
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		s.mu.Lock()
+		s.connections++
+		connection := s.connections
+		s.mu.Unlock()
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"topic": "system", "success": "testuser"}`))
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			s.record(string(message))
+
+			if !strings.HasPrefix(string(message), "smd+265598+") {
+				continue
+			}
+
+			conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(
+				`{"topic": "smd+265598", "conid": 265598, "_updated": 1702317649000, "31": "C19%d.5", "84": "192.4", "87": "1.2M", "server_id": "q0"}`,
+				connection,
+			)))
+
+			if connection == 1 {
+				return
+			}
+		}
+	}
+}
+
+func TestMarketDataStream_SubscribeAndReconnect(t *testing.T) {
+	server := &testStreamServer{}
+	mockServer := httptest.NewServer(server.handler(t))
+	defer mockServer.Close()
+
+	client := NewIbkrWebClient(mockServer.URL, &MockOAuthContext{})
+	client.RateLimiter = nil
+
+	stream := NewMarketDataStream(client)
+	stream.HeartbeatInterval = 20 * time.Millisecond
+	stream.ReconnectDelay = 10 * time.Millisecond
+
+	fields := []string{MarketDataFieldLastPrice, MarketDataFieldBid}
+	err := stream.Subscribe(265598, fields...)
+	assert.NoError(t, err)
+
+	// the subscription keeps its own copy of the fields
+	fields[0] = MarketDataFieldAsk
+
+	stream.Start()
+
+	update := <-stream.Updates()
+	assert.Equal(t, 265598, update.ConID)
+	assert.Equal(t, time.UnixMilli(1702317649000), update.Time)
+	assert.NotContains(t, update.Fields, "server_id")
+
+	lastPrice, ok := update.LastPrice()
+	assert.True(t, ok)
+	assert.Equal(t, 191.5, lastPrice)
+
+	bid, ok := update.Bid()
+	assert.True(t, ok)
+	assert.Equal(t, 192.4, bid)
+
+	volume, ok := update.Volume()
+	assert.True(t, ok)
+	assert.Equal(t, 1.2e6, volume)
+
+	_, ok = update.Ask()
+	assert.False(t, ok)
+
+	// the second connection must resubscribe without another Subscribe call
+	update = <-stream.Updates()
+	lastPrice, _ = update.LastPrice()
+	assert.Equal(t, 192.5, lastPrice)
+
+	assert.Eventually(t, func() bool { return server.received("tic") > 0 }, time.Second, 10*time.Millisecond)
+
+	err = stream.Unsubscribe(265598)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return server.received("umd+265598+") == 1 }, time.Second, 10*time.Millisecond)
+
+	stream.Stop()
+
+	_, open := <-stream.Updates()
+	assert.False(t, open)
+
+	assert.Equal(t, 2, server.received(`{"session":"bb665d0f55b6289d70bc7380089fc96f"}`))
+	assert.Equal(t, 2, server.received(`smd+265598+{"fields":["31","84"]}`))
+}
+
+func TestMarketDataStream_websocketUrl(t *testing.T) {
+	stream := NewMarketDataStream(NewIbkrWebClient("https://api.ibkr.com", &IbkrOAuthContext{AccessToken: "token"}))
+
+	wsUrl, err := stream.websocketUrl()
+	assert.NoError(t, err)
+	assert.Equal(t, "wss://api.ibkr.com/v1/api/ws?oauth_token=token", wsUrl)
+
+	stream = NewMarketDataStream(NewIbkrWebClient(GatewayBaseUrl, nil))
+
+	wsUrl, err = stream.websocketUrl()
+	assert.NoError(t, err)
+	assert.Equal(t, "wss://localhost:5000/v1/api/ws", wsUrl)
+}
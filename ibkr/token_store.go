@@ -0,0 +1,224 @@
+package ibkr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+type StoredToken struct {
+	ConsumerKey   string `json:"consumer_key"`
+	AccessToken   string `json:"access_token"`
+	Lst           string `json:"lst"`
+	LstExpiration int64  `json:"lst_expiration"`
+}
+
+// persists the live session token between process restarts. Load returns nil without an error
+// when no token has been stored.
+type TokenStore interface {
+	Load() (*StoredToken, error)
+	Save(token StoredToken) error
+	Clear() error
+}
+
+/******************************************************************************
+* in memory store
+******************************************************************************/
+
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token *StoredToken
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{}
+}
+
+func (s *MemoryTokenStore) Load() (*StoredToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == nil {
+		return nil, nil
+	}
+
+	token := *s.token
+	return &token, nil
+}
+
+func (s *MemoryTokenStore) Save(token StoredToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = &token
+	return nil
+}
+
+func (s *MemoryTokenStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = nil
+	return nil
+}
+
+/******************************************************************************
+* encrypted file store
+******************************************************************************/
+
+// file layout: version byte, scrypt salt, gcm nonce, ciphertext.
+const (
+	fileTokenStoreVersion  = 1
+	fileTokenStoreSaltSize = 16
+)
+
+// scrypt cost parameters, roughly 32MB of memory per key derivation.
+const (
+	fileTokenStoreScryptN = 1 << 15
+	fileTokenStoreScryptR = 8
+	fileTokenStoreScryptP = 1
+)
+
+type FileTokenStore struct {
+	Path   string
+	secret []byte
+}
+
+// the token is encrypted at rest with aes-256-gcm. the key is derived from the secret with scrypt
+// using a random salt stored in the file header, so a new salt is used on every save.
+func NewFileTokenStore(path string, secret []byte) *FileTokenStore {
+	return &FileTokenStore{
+		Path:   path,
+		secret: bytes.Clone(secret),
+	}
+}
+
+func (s *FileTokenStore) gcm(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(
+		s.secret,
+		salt,
+		fileTokenStoreScryptN,
+		fileTokenStoreScryptR,
+		fileTokenStoreScryptP,
+		32,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (s *FileTokenStore) Load() (*StoredToken, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 1+fileTokenStoreSaltSize {
+		return nil, fmt.Errorf("token store file too short: %s", s.Path)
+	}
+
+	if data[0] != fileTokenStoreVersion {
+		return nil, fmt.Errorf("unsupported token store file version %d: %s", data[0], s.Path)
+	}
+
+	salt, data := data[1:1+fileTokenStoreSaltSize], data[1+fileTokenStoreSaltSize:]
+
+	gcm, err := s.gcm(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("token store file too short: %s", s.Path)
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting token store file %s: %w", s.Path, err)
+	}
+
+	var token StoredToken
+	err = json.Unmarshal(plaintext, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (s *FileTokenStore) Save(token StoredToken) error {
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, fileTokenStoreSaltSize)
+	_, err = io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := s.gcm(salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return err
+	}
+
+	header := append([]byte{fileTokenStoreVersion}, salt...)
+	data := gcm.Seal(append(header, nonce...), nonce, plaintext, nil)
+
+	// write to a temp file first so a crash never leaves a partially written token behind
+	tempFile, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(data)
+	if err != nil {
+		tempFile.Close()
+		return err
+	}
+
+	err = tempFile.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), s.Path)
+}
+
+func (s *FileTokenStore) Clear() error {
+	err := os.Remove(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
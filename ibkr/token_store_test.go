@@ -0,0 +1,142 @@
+package ibkr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testStoredToken = StoredToken{
+	ConsumerKey:   "TESTCONS",
+	AccessToken:   "testtoken",
+	Lst:           "dGVzdGxzdA==",
+	LstExpiration: 1702317649,
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	store := NewMemoryTokenStore()
+
+	token, err := store.Load()
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+
+	assert.NoError(t, store.Save(testStoredToken))
+
+	token, err = store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, testStoredToken, *token)
+
+	assert.NoError(t, store.Clear())
+
+	token, err = store.Load()
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+}
+
+func TestFileTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lst")
+	store := NewFileTokenStore(path, []byte("secret"))
+
+	token, err := store.Load()
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+
+	assert.NoError(t, store.Save(testStoredToken))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), testStoredToken.Lst)
+
+	token, err = store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, testStoredToken, *token)
+
+	// every save uses a fresh salt
+	assert.NoError(t, store.Save(testStoredToken))
+
+	resaved, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(fileTokenStoreVersion), resaved[0])
+	assert.NotEqual(t, data[1:1+fileTokenStoreSaltSize], resaved[1:1+fileTokenStoreSaltSize])
+
+	_, err = NewFileTokenStore(path, []byte("wrong")).Load()
+	assert.Error(t, err)
+
+	assert.NoError(t, store.Clear())
+	assert.NoError(t, store.Clear())
+
+	token, err = store.Load()
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+}
+
+func TestIbkrOAuthContext_RestoreLiveSessionToken(t *testing.T) {
+	store := NewMemoryTokenStore()
+
+	oauth := newTestIbkrOAuthContext(t, testLstPrepend)
+	oauth.TokenStore = store
+
+	var hits atomic.Int32
+	lstServer := newTestLstServer(t, oauth, testLstPrepend, "")
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		lstServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer lstServer.Close()
+	defer mockServer.Close()
+
+	err := oauth.GenerateLiveSessionToken(mockServer.Client(), mockServer.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), hits.Load())
+
+	restarted := newTestIbkrOAuthContext(t, testLstPrepend)
+	restarted.TokenStore = store
+
+	err = restarted.GenerateLiveSessionToken(mockServer.Client(), mockServer.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), hits.Load())
+	assert.Equal(t, oauth.Lst, restarted.Lst)
+	assert.Equal(t, oauth.LstExpiration, restarted.LstExpiration)
+
+	restarted.Reset()
+
+	token, err := store.Load()
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+}
+
+func TestIbkrOAuthContext_RestoreLiveSessionTokenNearExpiry(t *testing.T) {
+	store := NewMemoryTokenStore()
+
+	oauth := newTestIbkrOAuthContext(t, testLstPrepend)
+	oauth.TokenStore = store
+
+	store.Save(StoredToken{
+		ConsumerKey:   oauth.ConsumerKey,
+		AccessToken:   oauth.AccessToken,
+		Lst:           "dGVzdGxzdA==",
+		LstExpiration: time.Now().Unix() + LstExpirationThreshold/2,
+	})
+
+	restored, err := oauth.RestoreLiveSessionToken()
+	assert.NoError(t, err)
+	assert.False(t, restored)
+
+	store.Save(StoredToken{
+		ConsumerKey:   "OTHERCONS",
+		AccessToken:   oauth.AccessToken,
+		Lst:           "dGVzdGxzdA==",
+		LstExpiration: time.Now().Add(time.Hour).Unix() + LstExpirationThreshold,
+	})
+
+	restored, err = oauth.RestoreLiveSessionToken()
+	assert.NoError(t, err)
+	assert.False(t, restored)
+	assert.Empty(t, oauth.Lst)
+}